@@ -0,0 +1,19 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ansiPattern matches ANSI escape sequences: CSI sequences such as colours
+// and cursor movement, and OSC sequences such as hyperlinks.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// stripANSI removes ANSI escape sequences from s, e.g. colours embedded in a
+// message by the caller, so files hold plain text.
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CallerField is the JSON key the call site is written under with
+// AddCaller.
+const CallerField = "caller"
+
+// packagePrefix begins the function name of every frame in this package.
+var packagePrefix = reflect.TypeOf(Logger{}).PkgPath() + "."
+
+// caller returns the file:line of the first frame outside the logger, so the
+// result is the same however many wrappers (Entry, Once, Writer) sit between
+// the user's call and logf. Only this package's own sources count as the
+// logger: its tests, subpackages and the example are callers like any other.
+func caller() string {
+	var pcs [16]uintptr
+	// Skip runtime.Callers and caller itself
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) || strings.HasSuffix(frame.File, "_test.go") {
+			return filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
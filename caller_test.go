@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// lineAbove returns the file:line of the line before the one it is called
+// from.
+func lineAbove() string {
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line-1)
+}
+
+func TestAddCallerReportsTheCallSite(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		l := newTestLogger(t, Config{AddCaller: true, Format: format})
+		var buf syncBuffer
+		l.SetOutput(&buf)
+
+		var want []string
+		l.Infof("direct")
+		want = append(want, lineAbove())
+		l.WithFields(map[string]interface{}{"k": 1}).Warningf("entry")
+		want = append(want, lineAbove())
+		l.Named("db").Named("pool").Errorf("named")
+		want = append(want, lineAbove())
+		l.Batch(INFO, []string{"event"})
+		want = append(want, lineAbove())
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		// The batch's event is on a line of its own in text
+		if format == "text" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) != len(want) {
+			t.Fatalf("%s: lines = %q, want %d", format, lines, len(want))
+		}
+		for i, line := range lines {
+			site := want[i]
+			if format == "json" {
+				var decoded map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+					t.Fatalf("invalid JSON %q: %v", line, err)
+				}
+				if decoded[CallerField] != site {
+					t.Errorf("json line %d: caller = %v, want %s", i, decoded[CallerField], site)
+				}
+			} else if !strings.Contains(line, " "+site) {
+				t.Errorf("text line %d = %q, want caller %s", i, line, site)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveExts are the extensions of archives written by any CompressCodec.
+var archiveExts = []string{".gz", ".zst"}
+
+// archived reports whether filename has already been archived, with any
+// codec.
+func archived(filename string) bool {
+	for _, ext := range archiveExts {
+		if _, err := os.Stat(filename + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compressFileZstd compresses inputPath into outputPath with zstd. level is
+// a CompressLevel, mapped onto the nearest zstd speed; gzip's default and
+// Huffman-only levels select zstd's default.
+func compressFileZstd(inputPath, outputPath string, level int) error {
+	encoderLevel := zstd.SpeedDefault
+	if level > 0 {
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+	return archiveFile(inputPath, outputPath, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(encoderLevel))
+	})
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// rotatedArchive writes one line, rotates, closes l so the archive is
+// finished, and returns the path of the archive.
+func rotatedArchive(t *testing.T, l *Logger, ext string) string {
+	t.Helper()
+	l.Infof("archived line")
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	var archives []string
+	for _, path := range files {
+		if strings.HasSuffix(path, ext) {
+			archives = append(archives, path)
+		}
+	}
+	if len(archives) != 1 {
+		t.Fatalf("files = %v, want one %s archive", files, ext)
+	}
+	return archives[0]
+}
+
+func TestCompressCodecZstd(t *testing.T) {
+	l := newTestLogger(t, Config{Compress: true, CompressCodec: "zstd"})
+	path := rotatedArchive(t, l, ".zst")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if magic := []byte{0x28, 0xb5, 0x2f, 0xfd}; !bytes.HasPrefix(data, magic) {
+		t.Errorf("%s starts with % .4x, want the zstd magic number", path, data)
+	}
+	if lines := readFileLines(t, path); len(lines) != 1 || !strings.HasSuffix(lines[0], "archived line") {
+		t.Errorf("%s = %q, want the archived line", path, lines)
+	}
+}
+
+func TestCompressLevel(t *testing.T) {
+	// gzip records the best and fastest levels in the header's XFL byte
+	for level, xfl := range map[int]byte{9: 2, 1: 4} {
+		l := newTestLogger(t, Config{Compress: true, CompressLevel: level})
+		path := rotatedArchive(t, l, ".gz")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) < 10 {
+			t.Fatalf("level %d: %s is only %d bytes", level, path, len(data))
+		}
+		if data[8] != xfl {
+			t.Errorf("level %d: header % x, want XFL %d", level, data[:10], xfl)
+		}
+		if lines := readFileLines(t, path); len(lines) != 1 {
+			t.Errorf("level %d: %s = %q, want the archived line", level, path, lines)
+		}
+	}
+}
+
+func TestInvalidCompressCodec(t *testing.T) {
+	if _, err := New("test", t.TempDir(), "app", Config{CompressCodec: "brotli"}); err == nil {
+		t.Error("New accepted CompressCodec brotli")
+	}
+}
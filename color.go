@@ -0,0 +1,41 @@
+//go:build !nocolor
+
+package logger
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+var dimColor = newColor(color.Faint)
+
+// newColor returns a color that paints regardless of color.NoColor; callers
+// decide whether to colour at all.
+func newColor(attribute color.Attribute) *color.Color {
+	c := color.New(attribute)
+	c.EnableColor()
+	return c
+}
+
+// streamColor reports whether console output to f should be coloured: f is
+// a terminal and neither NO_COLOR nor TERM=dumb ask otherwise.
+func streamColor(f *os.File) bool {
+	if f == os.Stdout {
+		// color.NoColor is set when stdout isn't a terminal or NO_COLOR is set
+		return !color.NoColor
+	}
+	return os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" &&
+		(isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// paint returns s in the colour of the ANSI SGR code.
+func paint(code int, s string) string {
+	return newColor(color.Attribute(code)).Sprint(s)
+}
+
+// paintDim returns s dimmed.
+func paintDim(s string) string {
+	return dimColor.Sprint(s)
+}
@@ -0,0 +1,20 @@
+//go:build nocolor
+
+package logger
+
+import "os"
+
+// Builds tagged nocolor leave out colour support, and with it the
+// github.com/fatih/color dependency. Output is always plain.
+
+func streamColor(f *os.File) bool {
+	return false
+}
+
+func paint(code int, s string) string {
+	return s
+}
+
+func paintDim(s string) string {
+	return s
+}
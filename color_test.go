@@ -0,0 +1,95 @@
+//go:build !nocolor
+
+package logger
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConsoleColorOnlyWhenColorized(t *testing.T) {
+	for _, colorize := range []bool{false, true} {
+		l := newTestLogger(t, Config{})
+		var console syncBuffer
+		setTestConsole(l, &console, colorize)
+		l.Warningf("careful")
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+
+		if got := strings.Contains(console.String(), "\x1b["); got != colorize {
+			t.Errorf("colorize %v: console %q has escape codes: %v", colorize, console.String(), got)
+		}
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	for _, noColor := range []bool{false, true} {
+		stdout := capture(t, &os.Stdout, func() {
+			// ForceColor would colour the pipe; NoColor takes precedence
+			l := newTestLogger(t, Config{Console: true, ForceColor: true, NoColor: noColor})
+			l.Errorf("failed")
+			if err := l.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		})
+
+		if !strings.Contains(stdout, "failed") {
+			t.Fatalf("NoColor %v: stdout = %q, want the console line", noColor, stdout)
+		}
+		if got := strings.Contains(stdout, "\x1b"); got == noColor {
+			t.Errorf("NoColor %v: stdout %q has ESC bytes: %v", noColor, stdout, got)
+		}
+	}
+}
+
+func TestLevelColors(t *testing.T) {
+	l := newTestLogger(t, Config{LevelColors: map[string]string{"error": "magenta"}})
+	var console syncBuffer
+	setTestConsole(l, &console, true)
+	l.Errorf("failed")
+	l.Warningf("careful")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(console.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("console = %q, want 2 lines", lines)
+	}
+	if !strings.HasPrefix(lines[0], "\x1b[35m") {
+		t.Errorf("ERROR line %q isn't magenta", lines[0])
+	}
+	// Levels without an override keep their default colour
+	if !strings.HasPrefix(lines[1], "\x1b[33m") {
+		t.Errorf("WARNING line %q isn't yellow", lines[1])
+	}
+}
+
+func TestParseLevelColorsRejectsUnknownNames(t *testing.T) {
+	for _, overrides := range []map[string]string{
+		{"loud": "red"},
+		{"error": "mauve"},
+	} {
+		if _, err := parseLevelColors(overrides); err == nil {
+			t.Errorf("parseLevelColors(%v) succeeded, want an error", overrides)
+		}
+	}
+}
+
+func TestPrettyColors(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "pretty"})
+	var console syncBuffer
+	setTestConsole(l, &console, true)
+
+	l.Errorf("failed")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	out := console.String()
+	if !strings.Contains(out, "\x1b[") || !strings.Contains(out, "failed") {
+		t.Errorf("console = %q, want a coloured line", out)
+	}
+}
@@ -0,0 +1,197 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NewWithConfig is like New but rejects an unknown Level or Frequency instead
+// of falling back to their defaults. Empty Level and Frequency still select
+// the defaults.
+func NewWithConfig(name, path, category string, config Config) (*Logger, error) {
+	err := validateConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+	return newLogger(name, path, category, &config)
+}
+
+// NewFromFile creates a logger from a YAML configuration file whose keys are
+// the lowercased Config field names, e.g.
+//
+//	level: debug
+//	frequency: hourly
+//	maxsize: 16MB
+//	console: true
+//
+// The configuration is validated like NewWithConfig.
+func NewFromFile(name, path, category, configFile string) (*Logger, error) {
+	config, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	logger, err := NewWithConfig(name, path, category, config)
+	if err != nil {
+		return nil, err
+	}
+	logger.configFile = configFile
+	logger.fileConfig = config
+	return logger, nil
+}
+
+// readConfigFile parses a YAML configuration file.
+func readConfigFile(configFile string) (Config, error) {
+	var config Config
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return config, err
+	}
+
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("parse %s: %w", configFile, err)
+	}
+	return config, nil
+}
+
+// NewFromEnv creates a logger configured from environment variables named
+// prefix followed by an underscore and the setting: PREFIX_LEVEL,
+// PREFIX_FREQUENCY, PREFIX_MAX_SIZE, PREFIX_CONSOLE and PREFIX_COMPRESS.
+// Booleans accept 1/true/yes and 0/false/no. Unset variables leave their
+// setting at its default. The configuration is validated like NewWithConfig.
+func NewFromEnv(name, path, category, prefix string) (*Logger, error) {
+	var config Config
+	config.Level = os.Getenv(prefix + "_LEVEL")
+	config.Frequency = os.Getenv(prefix + "_FREQUENCY")
+	config.MaxSize = os.Getenv(prefix + "_MAX_SIZE")
+
+	var err error
+	config.Console, err = envBool(prefix + "_CONSOLE")
+	if err != nil {
+		return nil, err
+	}
+	config.Compress, err = envBool(prefix + "_COMPRESS")
+	if err != nil {
+		return nil, err
+	}
+	return NewWithConfig(name, path, category, config)
+}
+
+// envBool reads a boolean environment variable, false when it is unset.
+func envBool(key string) (bool, error) {
+	value := os.Getenv(key)
+	switch strings.ToLower(value) {
+	case "", "0", "false", "no":
+		return false, nil
+	case "1", "true", "yes":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s: %s", key, value)
+	}
+}
+
+// ErrNoConfigFile is returned by Reload for a logger not created with
+// NewFromFile.
+var ErrNoConfigFile = errors.New("logger: not created from a config file")
+
+// reloadableFields are the Config fields Reload applies to a running logger.
+var reloadableFields = map[string]bool{
+	"Level":       true,
+	"Console":     true,
+	"Compress":    true,
+	"LevelColors": true,
+	"ForceColor":  true,
+	"NoColor":     true,
+}
+
+// Reload re-reads the file the logger was created from with NewFromFile,
+// e.g. on SIGHUP, and applies the settings that changed since the file was
+// last loaded. Level, Console, Compress, LevelColors, ForceColor and NoColor
+// can change; a setting left as it was in the file is not applied again, so
+// it doesn't undo a SetLevel made since. Other settings, such as Frequency,
+// can't change while the logger runs: a file changing them is rejected with
+// an error, as is a file that fails validation, and nothing is applied.
+func (l *Logger) Reload() error {
+	if l.configFile == "" {
+		return ErrNoConfigFile
+	}
+
+	config, err := readConfigFile(l.configFile)
+	if err != nil {
+		return err
+	}
+	err = validateConfig(&config)
+	if err != nil {
+		return err
+	}
+	levelColors, err := parseLevelColors(config.LevelColors)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changed := make(map[string]bool)
+	var fixed []string
+	previous := reflect.ValueOf(l.fileConfig)
+	loaded := reflect.ValueOf(config)
+	for i := 0; i < previous.NumField(); i++ {
+		name := previous.Type().Field(i).Name
+		if reflect.DeepEqual(previous.Field(i).Interface(), loaded.Field(i).Interface()) {
+			continue
+		}
+		if reloadableFields[name] {
+			changed[name] = true
+		} else {
+			fixed = append(fixed, name)
+		}
+	}
+	if len(fixed) > 0 {
+		return fmt.Errorf("reload: %s can't change while running; recreate the logger to apply", strings.Join(fixed, ", "))
+	}
+	l.fileConfig = config
+
+	if changed["Level"] {
+		level, ok := levelMapping[config.Level]
+		if !ok {
+			level = INFO
+		}
+		l.SetLevel(level)
+		l.config.Level = config.Level
+	}
+	if changed["Compress"] {
+		l.config.Compress = config.Compress
+	}
+	if changed["Console"] || changed["LevelColors"] || changed["ForceColor"] || changed["NoColor"] {
+		l.config.Console = config.Console
+		l.config.LevelColors = config.LevelColors
+		l.config.ForceColor = config.ForceColor
+		l.config.NoColor = config.NoColor
+
+		l.outMu.Lock()
+		l.levelColors = levelColors
+		l.setConsole()
+		l.outMu.Unlock()
+	}
+	return nil
+}
+
+// validateConfig reports the first setting New would silently replace with a
+// default.
+func validateConfig(config *Config) error {
+	if _, ok := levelMapping[config.Level]; !ok && config.Level != "" {
+		return fmt.Errorf("invalid level: %s", config.Level)
+	}
+	if _, ok := rollFrequencyMapping[config.Frequency]; !ok && config.Frequency != "" {
+		return fmt.Errorf("invalid frequency: %s", config.Frequency)
+	}
+	_, err := parseMaxSize(config.MaxSize)
+	return err
+}
@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newFileLogger writes config to a file and returns a logger created from
+// it, with a func to rewrite the file.
+func newFileLogger(t *testing.T, config string) (*Logger, func(string)) {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "logger.yaml")
+	write := func(config string) {
+		if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(config)
+	l, err := NewFromFile("test", t.TempDir(), "app", configFile)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	return l, write
+}
+
+func TestNewWithConfig(t *testing.T) {
+	l, err := NewWithConfig("test", t.TempDir(), "app", Config{Level: "warning", Frequency: "hourly"})
+	if err != nil {
+		t.Fatalf("NewWithConfig: %v", err)
+	}
+	defer l.Close()
+	l.Infof("filtered")
+	l.Warningf("kept")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 1 || !strings.HasSuffix(lines[0], "[WARNING] kept") {
+		t.Errorf("lines = %q, want only the WARNING", lines)
+	}
+
+	// New falls back to the defaults where NewWithConfig refuses
+	for _, config := range []Config{{Level: "loud"}, {Frequency: "sometimes"}, {MaxSize: "lots"}} {
+		if _, err := NewWithConfig("test", t.TempDir(), "app", config); err == nil {
+			t.Errorf("NewWithConfig accepted %+v", config)
+		}
+	}
+}
+
+func TestNewFromFile(t *testing.T) {
+	l, _ := newFileLogger(t, "level: error\nformat: json\nmaxsize: 16MB\n")
+	l.Warningf("filtered")
+	l.Errorf("kept")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	lines := readLines(t, l)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"message":"kept"`) {
+		t.Errorf("lines = %q, want only the ERROR, as JSON", lines)
+	}
+	if l.maxSize != 16<<20 {
+		t.Errorf("maxSize = %d, want 16MB", l.maxSize)
+	}
+}
+
+func TestNewFromFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewFromFile("test", dir, "app", filepath.Join(dir, "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: error = %v, want os.ErrNotExist", err)
+	}
+	for _, config := range []string{"level: [info\n", "level: loud\n"} {
+		configFile := filepath.Join(dir, "logger.yaml")
+		if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewFromFile("test", dir, "app", configFile); err == nil {
+			t.Errorf("NewFromFile accepted %q", config)
+		}
+	}
+}
+
+func TestReloadAppliesChanges(t *testing.T) {
+	l, write := newFileLogger(t, "level: info\nfrequency: daily\n")
+	write("level: error\nfrequency: daily\ncompress: true\n")
+	if err := l.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := l.GetLevel(); got != ERROR {
+		t.Errorf("level = %v, want ERROR", got)
+	}
+	if !l.config.Compress {
+		t.Error("Compress not applied")
+	}
+}
+
+func TestReloadKeepsRuntimeLevel(t *testing.T) {
+	l, _ := newFileLogger(t, "level: info\n")
+	l.SetLevel(DEBUG)
+	if err := l.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := l.GetLevel(); got != DEBUG {
+		t.Errorf("level = %v after reloading an unchanged file, want the DEBUG set at runtime", got)
+	}
+}
+
+func TestReloadRejectsFixedSettings(t *testing.T) {
+	l, write := newFileLogger(t, "level: info\nfrequency: daily\n")
+	write("level: error\nfrequency: hourly\n")
+	if err := l.Reload(); err == nil {
+		t.Fatal("Reload() = nil, want an error for the Frequency change")
+	}
+	// Nothing from the rejected file is applied
+	if got := l.GetLevel(); got != INFO {
+		t.Errorf("level = %v, want INFO", got)
+	}
+
+	write("level: loud\nfrequency: daily\n")
+	if err := l.Reload(); err == nil {
+		t.Error("Reload() = nil, want an error for the invalid level")
+	}
+}
+
+func TestReloadWithoutConfigFile(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	if err := l.Reload(); !errors.Is(err, ErrNoConfigFile) {
+		t.Errorf("Reload() = %v, want ErrNoConfigFile", err)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("APP_LOG_LEVEL", "warning")
+	t.Setenv("APP_LOG_FREQUENCY", "hourly")
+	t.Setenv("APP_LOG_MAX_SIZE", "1KB")
+	t.Setenv("APP_LOG_COMPRESS", "yes")
+	l, err := NewFromEnv("test", t.TempDir(), "app", "APP_LOG")
+	if err != nil {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+	defer l.Close()
+
+	if l.GetLevel() != WARNING || l.rollFrequency != HOURLY || l.maxSize != 1024 || !l.config.Compress || l.config.Console {
+		t.Errorf("level %v, frequency %v, max size %d, compress %v, console %v; want the environment's settings",
+			l.GetLevel(), l.rollFrequency, l.maxSize, l.config.Compress, l.config.Console)
+	}
+	l.Infof("hidden")
+	l.Warningf("shown")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 1 || !strings.HasSuffix(lines[0], "shown") {
+		t.Errorf("lines = %q, want only the warning", lines)
+	}
+}
+
+func TestNewFromEnvRejectsBadValues(t *testing.T) {
+	for key, value := range map[string]string{
+		"APP_LOG_LEVEL":    "loud",
+		"APP_LOG_CONSOLE":  "maybe",
+		"APP_LOG_MAX_SIZE": "-1MB",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if l, err := NewFromEnv("test", t.TempDir(), "app", "APP_LOG"); err == nil {
+				l.Close()
+				t.Errorf("NewFromEnv with %s=%s succeeded, want an error", key, value)
+			}
+		})
+	}
+}
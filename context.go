@@ -0,0 +1,40 @@
+package logger
+
+import "context"
+
+// AddContextExtractor registers fn to pull fields out of the context passed
+// to WithContext, e.g. a request or trace ID stored by a middleware. Fields
+// returned by later extractors override those of earlier ones. fn may return
+// nil when the context carries nothing of interest.
+func (l *Logger) AddContextExtractor(fn func(context.Context) map[string]interface{}) {
+	l.extractorsMu.Lock()
+	defer l.extractorsMu.Unlock()
+
+	// Copy on write, so WithContext can use its snapshot without the lock
+	extractors := make([]func(context.Context) map[string]interface{}, len(l.extractors), len(l.extractors)+1)
+	copy(extractors, l.extractors)
+	l.extractors = append(extractors, fn)
+}
+
+// WithContext returns an Entry carrying the fields the registered extractors
+// find in ctx. They are extracted immediately, so the entry can be used after
+// ctx is cancelled.
+func (l *Logger) WithContext(ctx context.Context) *Entry {
+	return l.entry().WithContext(ctx)
+}
+
+// WithContext returns a copy of the entry that also carries the fields the
+// logger's extractors find in ctx.
+func (e *Entry) WithContext(ctx context.Context) *Entry {
+	e.logger.extractorsMu.Lock()
+	extractors := e.logger.extractors
+	e.logger.extractorsMu.Unlock()
+
+	fields := make(map[string]interface{})
+	for _, extract := range extractors {
+		for key, value := range extract(ctx) {
+			fields[key] = value
+		}
+	}
+	return e.with(fields)
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type requestIDKey struct{}
+
+func TestWithContext(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	l.AddContextExtractor(func(ctx context.Context) map[string]interface{} {
+		id, ok := ctx.Value(requestIDKey{}).(string)
+		if !ok {
+			return nil
+		}
+		return map[string]interface{}{"request": id, "source": "first"}
+	})
+	// Later extractors override earlier ones
+	l.AddContextExtractor(func(ctx context.Context) map[string]interface{} {
+		return map[string]interface{}{"source": "second"}
+	})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), requestIDKey{}, "r42"))
+	entry := l.WithFields(map[string]interface{}{"user": "ann"}).WithContext(ctx)
+	// The fields were taken when the entry was made
+	cancel()
+	entry.Infof("handled")
+	l.WithContext(context.Background()).Infof("no request")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2: %q", len(lines), lines)
+	}
+	if want := "[INFO]    handled request=r42 source=second user=ann"; !strings.HasSuffix(lines[0], want) {
+		t.Errorf("line = %q, want it to end with %q", lines[0], want)
+	}
+	if want := "[INFO]    no request source=second"; !strings.HasSuffix(lines[1], want) {
+		t.Errorf("line = %q, want it to end with %q", lines[1], want)
+	}
+}
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// currentLinkName is the symlink CurrentLink maintains in the category
+// directory.
+const currentLinkName = "current.log"
+
+// updateCurrentLink points the current.log symlink at the active file. It is
+// a no-op unless CurrentLink is set. Where symlinks aren't available, e.g.
+// on Windows without the privilege, the failure is reported once and the
+// link is no longer maintained. It must be called with l.mu held.
+func (l *Logger) updateCurrentLink() {
+	if !l.config.CurrentLink || l.currentLinkFailed || l.file == nil {
+		return
+	}
+
+	categoryDir := filepath.Join(l.path, l.category)
+	target, err := filepath.Rel(categoryDir, l.file.Name())
+	if err != nil {
+		target = l.file.Name()
+	}
+
+	// Swap in a new link with a rename, so readers never see it missing
+	link := filepath.Join(categoryDir, currentLinkName)
+	tmp := link + ".tmp"
+	_ = os.Remove(tmp)
+	err = os.Symlink(target, tmp)
+	if err == nil {
+		err = os.Rename(tmp, link)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		l.currentLinkFailed = true
+		l.reportError(fmt.Errorf("current link: %w", err))
+	}
+}
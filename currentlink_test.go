@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCurrentLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need a privilege on Windows")
+	}
+	l := newTestLogger(t, Config{CurrentLink: true})
+	link := filepath.Join(l.path, l.category, currentLinkName)
+
+	// checkLink asserts that link resolves to the active file and reads
+	// back the line last written
+	checkLink := func(line string) {
+		t.Helper()
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		active, err := l.activeFile(false)
+		if err != nil {
+			t.Fatalf("activeFile: %v", err)
+		}
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			t.Fatalf("EvalSymlinks: %v", err)
+		}
+		want, err := filepath.EvalSymlinks(active)
+		if err != nil {
+			t.Fatalf("EvalSymlinks: %v", err)
+		}
+		if target != want {
+			t.Errorf("%s points at %s, want %s", currentLinkName, target, want)
+		}
+
+		data, err := os.ReadFile(link)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if got := strings.TrimSuffix(string(data), "\n"); !strings.HasSuffix(got, line) {
+			t.Errorf("%s holds %q, want it to end with %q", currentLinkName, got, line)
+		}
+	}
+
+	l.Infof("first file")
+	checkLink("first file")
+
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	l.Infof("second file")
+	checkLink("second file")
+
+	// The link is not mistaken for a log file
+	if files, err := l.Files(); err != nil {
+		t.Fatalf("Files: %v", err)
+	} else if len(files) != 2 {
+		t.Errorf("Files() = %v, want the 2 log files", files)
+	}
+}
+
+func TestCurrentLinkOff(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	l.Infof("no link")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	link := filepath.Join(l.path, l.category, currentLinkName)
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("Lstat(%s) error = %v, want it not to exist", currentLinkName, err)
+	}
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dedupMaxKeys bounds how many distinct messages DedupWindow tracks. Once
+// it is reached, new messages are logged without deduplication until
+// windows close.
+const dedupMaxKeys = 1024
+
+// dedupWindow is a message being deduplicated: the entry that opened the
+// window and how many duplicates were suppressed since.
+type dedupWindow struct {
+	entry   LogContent
+	start   time.Time
+	repeats int
+}
+
+// deduper collapses identical messages logged within DedupWindow.
+type deduper struct {
+	mu      sync.Mutex
+	window  time.Duration
+	windows map[string]*dedupWindow
+}
+
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{window: window, windows: make(map[string]*dedupWindow)}
+}
+
+// dedupKey identifies duplicates: the same level and formatted message.
+func dedupKey(entry LogContent) string {
+	return entry.Level.String() + "\x00" + entry.Message
+}
+
+// dedupAllow reports whether entry should be logged, or is a duplicate
+// within the window of an earlier one. When the earlier window has already
+// expired its summary is queued first.
+func (l *Logger) dedupAllow(entry LogContent) bool {
+	d := l.dedup
+	key := dedupKey(entry)
+
+	d.mu.Lock()
+	w, ok := d.windows[key]
+	if ok && entry.Timestamp.Sub(w.start) < d.window {
+		w.repeats++
+		d.mu.Unlock()
+		return false
+	}
+	var summary *LogContent
+	if ok && w.repeats > 0 {
+		s := d.summary(w)
+		summary = &s
+	}
+	if ok || len(d.windows) < dedupMaxKeys {
+		d.windows[key] = &dedupWindow{entry: entry, start: entry.Timestamp}
+	}
+	d.mu.Unlock()
+
+	if summary != nil {
+		l.enqueue(*summary)
+	}
+	return true
+}
+
+// flushDedup queues the summaries of the windows that have closed, or of
+// all of them when all is set, and forgets those windows.
+func (l *Logger) flushDedup(all bool) {
+	d := l.dedup
+	now := time.Now()
+
+	var summaries []LogContent
+	d.mu.Lock()
+	for key, w := range d.windows {
+		if !all && now.Sub(w.start) < d.window {
+			continue
+		}
+		if w.repeats > 0 {
+			summaries = append(summaries, d.summary(w))
+		}
+		delete(d.windows, key)
+	}
+	d.mu.Unlock()
+
+	for _, summary := range summaries {
+		l.enqueue(summary)
+	}
+}
+
+// summary returns the line reporting w's suppressed duplicates. It must be
+// called with d.mu held.
+func (d *deduper) summary(w *dedupWindow) LogContent {
+	entry := w.entry
+	entry.Timestamp = time.Now()
+	entry.Message = fmt.Sprintf("%s (repeated %d times in %s)", entry.Message, w.repeats, d.window)
+	entry.StackTrace = ""
+	return entry
+}
+
+// runDedupFlusher queues the summaries of closed windows every interval
+// until the logger is stopped.
+func (l *Logger) runDedupFlusher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.quit:
+			return
+		case <-ticker.C:
+			l.flushDedup(false)
+		}
+	}
+}
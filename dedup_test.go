@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupWindow(t *testing.T) {
+	l := newTestLogger(t, Config{DedupWindow: time.Hour})
+	for i := 0; i < 5; i++ {
+		l.Infof("connection refused")
+	}
+	l.Infof("other")
+	l.Warningf("connection refused")
+	// Closing ends the open windows
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := readLines(t, l)
+	want := []string{
+		"[INFO]    connection refused",
+		"[INFO]    other",
+		"[WARNING] connection refused",
+		"[INFO]    connection refused (repeated 4 times in 1h0m0s)",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("lines = %q, want %d", lines, len(want))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("line %d = %q, want it to end with %q", i, line, want[i])
+		}
+	}
+}
+
+func TestDedupWindowCloses(t *testing.T) {
+	l := newTestLogger(t, Config{DedupWindow: 20 * time.Millisecond})
+	l.Infof("flapping")
+	l.Infof("flapping")
+	time.Sleep(30 * time.Millisecond)
+	// A duplicate after the window is written, with the summary before it
+	l.Infof("flapping")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], "flapping (repeated 1 times in 20ms)") || !strings.HasSuffix(lines[2], "flapping") {
+		t.Errorf("lines = %q, want the first, the summary and the late duplicate", lines)
+	}
+}
@@ -0,0 +1,177 @@
+// Package encrypt implements the append-only encrypted file format used by
+// the logger for files ending in .log.enc.
+//
+// A file is a sequence of independent chunks, one per write:
+//
+//	length (4 bytes, big endian) | nonce (12 bytes) | AES-GCM ciphertext and tag
+//
+// where length covers the nonce and ciphertext. Every chunk is sealed with a
+// fresh random nonce, so files can be appended to across restarts, and a file
+// cut short by a crash stays readable up to its last complete chunk. Chunks
+// are authenticated individually: tampering within a chunk is detected, but
+// removing or reordering whole chunks is not.
+package encrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	lengthSize   = 4
+	maxChunkSize = 16 * 1024 * 1024
+)
+
+// Overhead is the number of bytes each chunk adds to the plaintext written.
+const Overhead = lengthSize + 12 + 16
+
+// ErrCorrupt is returned when a chunk fails authentication or is malformed.
+var ErrCorrupt = errors.New("encrypt: corrupt or tampered chunk")
+
+// newAEAD returns AES-GCM for a 16, 24 or 32 byte key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ValidateKey reports whether key can be used to encrypt files.
+func ValidateKey(key []byte) error {
+	_, err := newAEAD(key)
+	return err
+}
+
+// Writer seals each Write as one chunk written to the underlying writer.
+type Writer struct {
+	w    io.Writer
+	aead cipher.AEAD
+}
+
+// NewWriter returns a Writer encrypting to w with key, which must be 16, 24
+// or 32 bytes long.
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead}, nil
+}
+
+// Write encrypts p as a single chunk. The chunk is written with one call to
+// the underlying writer; on success len(p) is returned.
+func (ew *Writer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(p) > maxChunkSize {
+		return 0, fmt.Errorf("encrypt: write of %d bytes exceeds the %d byte chunk limit", len(p), maxChunkSize)
+	}
+
+	nonceSize := ew.aead.NonceSize()
+	chunk := make([]byte, lengthSize+nonceSize, lengthSize+nonceSize+len(p)+ew.aead.Overhead())
+	nonce := chunk[lengthSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	chunk = ew.aead.Seal(chunk, nonce, p, nil)
+	binary.BigEndian.PutUint32(chunk[:lengthSize], uint32(len(chunk)-lengthSize))
+
+	if _, err := ew.w.Write(chunk); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Reader decrypts a chunked stream.
+type Reader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte
+	err  error
+}
+
+// NewReader returns a Reader decrypting r with key.
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: bufio.NewReader(r), aead: aead}, nil
+}
+
+// Read returns decrypted bytes. A trailing incomplete chunk, as left by a
+// crash mid-write, ends the stream like io.EOF.
+func (er *Reader) Read(p []byte) (int, error) {
+	for len(er.buf) == 0 {
+		if er.err != nil {
+			return 0, er.err
+		}
+		er.buf, er.err = er.readChunk()
+	}
+
+	n := copy(p, er.buf)
+	er.buf = er.buf[n:]
+	return n, nil
+}
+
+func (er *Reader) readChunk() ([]byte, error) {
+	var header [lengthSize]byte
+	_, err := io.ReadFull(er.r, header[:])
+	if err != nil {
+		// Clean end of stream, or a crash while writing the header
+		return nil, io.EOF
+	}
+
+	length := binary.BigEndian.Uint32(header[:])
+	nonceSize := er.aead.NonceSize()
+	if length < uint32(nonceSize+er.aead.Overhead()) || length > maxChunkSize+uint32(nonceSize+er.aead.Overhead()) {
+		return nil, ErrCorrupt
+	}
+
+	chunk := make([]byte, length)
+	_, err = io.ReadFull(er.r, chunk)
+	if err != nil {
+		// Incomplete trailing chunk
+		return nil, io.EOF
+	}
+
+	plaintext, err := er.aead.Open(nil, chunk[:nonceSize], chunk[nonceSize:], nil)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plaintext, nil
+}
+
+type readCloser struct {
+	*Reader
+	file *os.File
+}
+
+func (rc readCloser) Close() error {
+	return rc.file.Close()
+}
+
+// OpenEncrypted opens an encrypted log file written with key and returns a
+// reader of its plaintext.
+func OpenEncrypted(path string, key []byte) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewReader(file, key)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return readCloser{Reader: reader, file: file}, nil
+}
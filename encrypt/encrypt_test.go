@@ -0,0 +1,101 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+// encryptChunks returns the stream written by one Write per chunk.
+func encryptChunks(t *testing.T, chunks ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testKey)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, chunk := range chunks {
+		n, err := w.Write([]byte(chunk))
+		if err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// decrypt reads the plaintext of stream with key.
+func decrypt(stream, key []byte) (string, error) {
+	r, err := NewReader(bytes.NewReader(stream), key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := io.ReadAll(r)
+	return string(plain), err
+}
+
+func TestRoundTrip(t *testing.T) {
+	stream := encryptChunks(t, "first line\n", "second line\n")
+	if want := len("first line\n") + len("second line\n") + 2*Overhead; len(stream) != want {
+		t.Errorf("stream is %d bytes, want %d", len(stream), want)
+	}
+	plain, err := decrypt(stream, testKey)
+	if err != nil || plain != "first line\nsecond line\n" {
+		t.Errorf("decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestTruncatedStreamReadsCompleteChunks(t *testing.T) {
+	stream := encryptChunks(t, "kept\n", "cut short\n")
+	for _, cut := range []int{1, Overhead, len(stream) - len("kept\n") - Overhead - 1} {
+		plain, err := decrypt(stream[:len(stream)-cut], testKey)
+		if err != nil || plain != "kept\n" {
+			t.Errorf("cut %d bytes: decrypt = %q, %v; want the first chunk", cut, plain, err)
+		}
+	}
+}
+
+func TestTamperingIsDetected(t *testing.T) {
+	stream := encryptChunks(t, "secret\n")
+	stream[len(stream)-1] ^= 1
+	if _, err := decrypt(stream, testKey); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("tampered chunk: err = %v, want ErrCorrupt", err)
+	}
+
+	otherKey := bytes.Repeat([]byte{8}, 32)
+	if _, err := decrypt(encryptChunks(t, "secret\n"), otherKey); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("wrong key: err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		if err := ValidateKey(make([]byte, size)); err != nil {
+			t.Errorf("%d byte key: %v", size, err)
+		}
+	}
+	if err := ValidateKey(make([]byte, 10)); err == nil {
+		t.Error("10 byte key accepted")
+	}
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.log.enc")
+	if err := os.WriteFile(path, encryptChunks(t, "on disk\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenEncrypted(path, testKey)
+	if err != nil {
+		t.Fatalf("OpenEncrypted: %v", err)
+	}
+	defer r.Close()
+	plain, err := io.ReadAll(r)
+	if err != nil || string(plain) != "on disk\n" {
+		t.Errorf("ReadAll = %q, %v", plain, err)
+	}
+}
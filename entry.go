@@ -0,0 +1,107 @@
+package logger
+
+// Entry is a set of fields bound to a Logger. Its logging methods behave like
+// the Logger's own, with the fields attached to every line. Entries share the
+// parent's queue, level and outputs; they are cheap and safe to discard.
+type Entry struct {
+	logger *Logger
+	fields map[string]interface{}
+}
+
+// entry returns an Entry carrying the logger's configured fields.
+func (l *Logger) entry() *Entry {
+	return &Entry{logger: l, fields: l.fields}
+}
+
+// WithFields returns an Entry carrying fields in addition to the logger's
+// configured fields. In text output they are appended to the message as
+// key=value pairs; in JSON output they become top-level keys.
+func (l *Logger) WithFields(fields map[string]interface{}) *Entry {
+	return l.entry().with(fields)
+}
+
+// WithFields returns a copy of the entry that also carries fields. Keys
+// already on the entry are overwritten; the others are kept.
+func (e *Entry) WithFields(fields map[string]interface{}) *Entry {
+	return e.with(fields)
+}
+
+// with returns a new Entry carrying the receiver's fields merged with fields,
+// the latter taking precedence on duplicate keys.
+func (e *Entry) with(fields map[string]interface{}) *Entry {
+	merged := make(map[string]interface{}, len(e.fields)+len(fields))
+	for key, value := range e.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &Entry{logger: e.logger, fields: merged}
+}
+
+// ErrorField is the field name WithError attaches errors under.
+const ErrorField = "error"
+
+// WithError returns an Entry carrying err as the error field. A nil error
+// adds no field.
+func (l *Logger) WithError(err error) *Entry {
+	return l.entry().WithError(err)
+}
+
+// WithError returns a copy of the entry that also carries err as the error
+// field. Text output renders the error's message; the error value itself is
+// kept so structured formats can report its type. A nil error adds no field.
+func (e *Entry) WithError(err error) *Entry {
+	if err == nil {
+		return e
+	}
+	return e.with(map[string]interface{}{ErrorField: err})
+}
+
+// ComponentField is the field name Named attaches the component under.
+const ComponentField = "component"
+
+// Named returns an Entry tagging every line with component, for a part of
+// the application that shares the logger's file, queue and level.
+func (l *Logger) Named(component string) *Entry {
+	return l.entry().Named(component)
+}
+
+// Named returns a copy of the entry tagged with component. An entry that is
+// already named gets the components joined with a dot, e.g. "db.pool".
+func (e *Entry) Named(component string) *Entry {
+	if parent, ok := e.fields[ComponentField].(string); ok && parent != "" {
+		component = parent + "." + component
+	}
+	return e.with(map[string]interface{}{ComponentField: component})
+}
+
+// Batch logs related events as a single entry carrying the entry's fields.
+func (e *Entry) Batch(level LogLevel, events []string) {
+	e.logger.batch(level, e.fields, events)
+}
+
+func (e *Entry) Debugf(format string, v ...interface{}) {
+	e.logger.logf(DEBUG, e.fields, format, v...)
+}
+
+func (e *Entry) Infof(format string, v ...interface{}) {
+	e.logger.logf(INFO, e.fields, format, v...)
+}
+
+func (e *Entry) Jedif(format string, v ...interface{}) {
+	e.logger.logf(JEDI, e.fields, format, v...)
+}
+
+func (e *Entry) Warningf(format string, v ...interface{}) {
+	e.logger.logf(WARNING, e.fields, format, v...)
+}
+
+func (e *Entry) Errorf(format string, v ...interface{}) {
+	e.logger.logf(ERROR, e.fields, format, v...)
+}
+
+func (e *Entry) Fatalf(format string, v ...interface{}) {
+	e.logger.logf(FATAL, e.fields, format, v...)
+	e.logger.exit()
+}
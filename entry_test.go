@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestConfigFieldsOnEveryLine(t *testing.T) {
+	fields := map[string]string{"service": "api", "env": "prod"}
+
+	text := newTestLogger(t, Config{Fields: fields})
+	var textBuf syncBuffer
+	text.SetOutput(&textBuf)
+	text.Infof("plain")
+	text.WithFields(map[string]interface{}{"env": "staging", "user": 7}).Warningf("extended")
+	if err := text.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(textBuf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q, want 2", lines)
+	}
+	if !strings.Contains(lines[0], "env=prod service=api") {
+		t.Errorf("line %q is missing the config fields", lines[0])
+	}
+	// Entry fields extend the config fields and win on duplicate keys
+	if !strings.Contains(lines[1], "env=staging service=api user=7") {
+		t.Errorf("line %q doesn't extend the config fields", lines[1])
+	}
+
+	structured := newTestLogger(t, Config{Fields: fields, Format: "json"})
+	var jsonBuf syncBuffer
+	structured.SetOutput(&jsonBuf)
+	structured.Infof("structured")
+	if err := structured.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonBuf.String()), &line); err != nil {
+		t.Fatalf("invalid JSON %q: %v", jsonBuf.String(), err)
+	}
+	if line["service"] != "api" || line["env"] != "prod" {
+		t.Errorf("JSON line %v is missing top-level config fields", line)
+	}
+}
+
+func TestWithError(t *testing.T) {
+	simple := errors.New("boom")
+	wrapped := fmt.Errorf("read config: %w", simple)
+
+	text := newTestLogger(t, Config{})
+	var textBuf syncBuffer
+	text.SetOutput(&textBuf)
+	text.WithError(nil).Errorf("nil")
+	text.WithError(simple).Errorf("simple")
+	text.WithError(wrapped).Errorf("wrapped")
+	if err := text.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(textBuf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q, want 3", lines)
+	}
+	if strings.Contains(lines[0], ErrorField+"=") {
+		t.Errorf("nil error added a field: %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "simple error=boom") {
+		t.Errorf("simple error line = %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "wrapped error=read config: boom") {
+		t.Errorf("wrapped error line = %q", lines[2])
+	}
+
+	structured := newTestLogger(t, Config{Format: "json"})
+	var jsonBuf syncBuffer
+	structured.SetOutput(&jsonBuf)
+	structured.WithError(wrapped).Errorf("wrapped")
+	if err := structured.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	var line struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(jsonBuf.String()), &line); err != nil {
+		t.Fatalf("invalid JSON %q: %v", jsonBuf.String(), err)
+	}
+	if line.Error.Message != "read config: boom" || line.Error.Type != "*fmt.wrapError" {
+		t.Errorf("JSON error field = %+v", line.Error)
+	}
+}
+
+func TestNamedChildrenShareTheFile(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	goroutines := runtime.NumGoroutine()
+	db := l.Named("db")
+	pool := db.Named("pool")
+	http := l.Named("http")
+	if n := runtime.NumGoroutine(); n != goroutines {
+		t.Errorf("%d goroutines after Named, want %d", n, goroutines)
+	}
+
+	for i := 0; i < 3; i++ {
+		db.Infof("query %d", i)
+		http.Infof("request %d", i)
+	}
+	pool.Warningf("exhausted")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Files() = %v, %v; want the one file", files, err)
+	}
+	want := []string{
+		"query 0 component=db", "request 0 component=http",
+		"query 1 component=db", "request 1 component=http",
+		"query 2 component=db", "request 2 component=http",
+		"exhausted component=db.pool",
+	}
+	lines := readFileLines(t, files[0])
+	if len(lines) != len(want) {
+		t.Fatalf("lines = %q, want %d", lines, len(want))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("line %d = %q, want it to end with %q", i, line, want[i])
+		}
+	}
+}
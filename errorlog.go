@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// errorLogName is the file SeparateErrorLog writes in each dated directory.
+const errorLogName = "error.log"
+
+// errorLogPattern matches error logs and their archives.
+var errorLogPattern = regexp.MustCompile(`^error\.log(\.gz|\.zst|\.enc)?$`)
+
+// errorLogFileName returns the name of the error log, which is encrypted
+// like the main file when an EncryptionKey is configured.
+func (l *Logger) errorLogFileName() string {
+	if l.config.EncryptionKey != nil {
+		return errorLogName + ".enc"
+	}
+	return errorLogName
+}
+
+// openErrorLog opens the error log in the directory of the current period
+// unless it is already open. It is called before the first ERROR line of a
+// period is written, so periods without errors leave no error log behind. It
+// is a no-op unless SeparateErrorLog is set and must be called from the
+// writing goroutine with l.mu held.
+func (l *Logger) openErrorLog() {
+	if !l.config.SeparateErrorLog || l.errorWriter != nil || l.periodClosed {
+		return
+	}
+
+	filename := filepath.Join(l.path, l.category, l.periodDir(l.lastRotateTime), l.errorLogFileName())
+	errorWriter, err := l.openFileWriter(filename)
+	if err != nil {
+		l.reportError(fmt.Errorf("error log: %w", err))
+		return
+	}
+	l.outMu.Lock()
+	l.errorWriter = errorWriter
+	l.outMu.Unlock()
+}
+
+// closeErrorLog closes the error log, if one is open. It must be called with
+// l.mu held.
+func (l *Logger) closeErrorLog() {
+	if l.errorWriter == nil {
+		return
+	}
+
+	l.outMu.Lock()
+	err := l.errorWriter.Close()
+	l.errorWriter = nil
+	l.outMu.Unlock()
+	if err != nil {
+		l.reportError(fmt.Errorf("error log: %w", err))
+	}
+}
+
+// writeErrorLog copies a rendered line at level to the error log when the
+// level is ERROR or above.
+func (l *Logger) writeErrorLog(level LogLevel, line string) {
+	if level < ERROR {
+		return
+	}
+
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+
+	if l.errorWriter == nil {
+		return
+	}
+	err := l.writeTo(l.errorWriter, line)
+	if err != nil {
+		l.reportError(fmt.Errorf("error log: %w", err))
+	}
+}
+
+// listErrorLogs returns the paths of every error log and archive of the
+// logger's category.
+func (l *Logger) listErrorLogs() ([]string, error) {
+	logCategoryDir := filepath.Join(l.path, l.category)
+	dirs, err := os.ReadDir(logCategoryDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(logCategoryDir, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if errorLogPattern.MatchString(file.Name()) {
+				paths = append(paths, filepath.Join(logCategoryDir, dir.Name(), file.Name()))
+			}
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorLogOpenedByFirstError(t *testing.T) {
+	l := newTestLogger(t, Config{SeparateErrorLog: true})
+	errorLog := filepath.Join(l.path, l.category, l.periodDir(time.Now()), errorLogName)
+
+	l.Infof("fine")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if _, err := os.Stat(errorLog); !os.IsNotExist(err) {
+		t.Fatalf("error log exists before any error: %v", err)
+	}
+
+	l.Errorf("broken")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	lines := readFileLines(t, errorLog)
+	if len(lines) != 1 || !strings.Contains(lines[0], "broken") {
+		t.Errorf("error log = %q, want the one ERROR line", lines)
+	}
+}
+
+func TestPurgeRemovesErrorLogs(t *testing.T) {
+	l := newTestLogger(t, Config{SeparateErrorLog: true})
+	old := filepath.Join(l.path, l.category, l.periodDir(time.Now().AddDate(0, 0, -3)))
+	if err := os.MkdirAll(old, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"1.log.gz", errorLogName + ".gz"} {
+		if err := os.WriteFile(filepath.Join(old, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.Errorf("active")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if err := l.Purge(); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("dated directory left behind: %v", err)
+	}
+	active := filepath.Join(l.path, l.category, l.periodDir(time.Now()), errorLogName)
+	if _, err := os.Stat(active); err != nil {
+		t.Errorf("active error log removed: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package logger
+
+import "time"
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// Rotated is sent when a log file is closed, whether by rotation or at
+	// the end of its period. Path is the closed file.
+	Rotated EventType = iota + 1
+	// Compressed is sent when a rotated file has been archived, or with Err
+	// set when archiving failed after all retries. Path is the archive on
+	// success and the original file on failure.
+	Compressed
+	// Dropped is sent when entries are not written, because the queue was
+	// full or a write timed out. Count is how many.
+	Dropped
+	// WriteError is sent when writing to the file or the console fails. Path
+	// is the file, empty for the console and custom outputs.
+	WriteError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Rotated:
+		return "rotated"
+	case Compressed:
+		return "compressed"
+	case Dropped:
+		return "dropped"
+	case WriteError:
+		return "write error"
+	default:
+		return "unknown"
+	}
+}
+
+// eventBufferSize is how many events wait for a reader before new ones are
+// dropped.
+const eventBufferSize = 64
+
+// Event describes something that happened to the logger's files or
+// outputs, for metrics or alerting.
+type Event struct {
+	Type  EventType
+	Time  time.Time
+	Path  string
+	Count uint64
+	Err   error
+}
+
+// Events returns the channel lifecycle events are sent on. Events are sent
+// without blocking: when nobody reads them and the channel's buffer is full,
+// new events are discarded. The channel is shared by all callers and never
+// closed.
+func (l *Logger) Events() <-chan Event {
+	return l.events
+}
+
+// emit sends event without blocking, stamping it with the current time.
+func (l *Logger) emit(event Event) {
+	event.Time = time.Now()
+	select {
+	case l.events <- event:
+	default:
+	}
+}
+
+// drop counts n entries that were not written.
+func (l *Logger) drop(n uint64) {
+	l.dropped.Add(n)
+	l.emit(Event{Type: Dropped, Count: n})
+}
@@ -18,6 +18,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	defer log.Close()
 
 	for {
 		go log.Debugf(uuid.New().String())
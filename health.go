@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"sort"
+	"time"
+)
+
+// healthDebounce is how long the health state must hold before OnUnhealthy
+// callbacks are told about it, so a briefly flapping sink doesn't trip them
+// repeatedly.
+const healthDebounce = time.Second
+
+// OnUnhealthy registers fn to be called with the cause when the logger
+// becomes unhealthy (see Healthy) and with nil when it recovers. Calls are
+// made from a separate goroutine, never the writer, and only once a state
+// has held for a second. Registering again replaces the previous callback.
+// A logger from NewNop never becomes unhealthy and doesn't call fn.
+func (l *Logger) OnUnhealthy(fn func(error)) {
+	l.healthMu.Lock()
+	l.unhealthyFn = fn
+	l.healthMu.Unlock()
+
+	l.healthNotifier.Do(func() {
+		// NewNop loggers have nothing to stop the notifier with
+		if l.quit == nil {
+			return
+		}
+		changed := make(chan struct{}, 1)
+		l.healthMu.Lock()
+		l.healthChanged = changed
+		l.healthMu.Unlock()
+		go l.runHealthNotifier(changed)
+	})
+	l.signalHealthChange()
+}
+
+// signalHealthChange wakes the notifier goroutine, if one is running.
+func (l *Logger) signalHealthChange() {
+	l.healthMu.Lock()
+	changed := l.healthChanged
+	l.healthMu.Unlock()
+
+	if changed == nil {
+		return
+	}
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// runHealthNotifier reports settled health transitions to the OnUnhealthy
+// callback until the logger is stopped. changed is signalled on every health
+// change.
+func (l *Logger) runHealthNotifier(changed <-chan struct{}) {
+	unhealthy := false
+
+	for {
+		select {
+		case <-l.quit:
+			return
+		case <-changed:
+		}
+
+		// Let the state settle; changes in the meantime are coalesced
+		timer := time.NewTimer(healthDebounce)
+		select {
+		case <-l.quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		l.healthMu.Lock()
+		err := l.healthErr()
+		fn := l.unhealthyFn
+		l.healthMu.Unlock()
+
+		if (err != nil) == unhealthy {
+			continue
+		}
+		unhealthy = err != nil
+		if fn != nil {
+			fn(err)
+		}
+	}
+}
+
+// healthErr returns the error of the first unhealthy source in name order, or
+// nil when the logger is healthy. It must be called with l.healthMu held.
+func (l *Logger) healthErr() error {
+	if len(l.healthErrs) == 0 {
+		return nil
+	}
+
+	sources := make([]string, 0, len(l.healthErrs))
+	for source := range l.healthErrs {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return l.healthErrs[sources[0]]
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestOnUnhealthy(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	calls := make(chan error, 4)
+	l.OnUnhealthy(func(err error) { calls <- err })
+
+	expect := func(want error) {
+		t.Helper()
+		select {
+		case got := <-calls:
+			if got != want {
+				t.Fatalf("callback got %v, want %v", got, want)
+			}
+		case <-time.After(3 * healthDebounce):
+			t.Fatalf("no callback, want %v", want)
+		}
+	}
+
+	failure := errors.New("disk full")
+	l.setHealth("file", failure)
+	expect(failure)
+	l.setHealth("file", nil)
+	expect(nil)
+
+	// A failure that clears within the debounce doesn't flap the callback
+	l.setHealth("file", failure)
+	l.setHealth("file", nil)
+	select {
+	case got := <-calls:
+		t.Errorf("callback called with %v for a brief failure", got)
+	case <-time.After(2 * healthDebounce):
+	}
+}
+
+func TestOnUnhealthyRacesWithWriterSignals(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		close(started)
+		for i := 0; i < 1000; i++ {
+			l.setHealth("file", errors.New("disk full"))
+			l.setHealth("file", nil)
+		}
+	}()
+	<-started
+	l.OnUnhealthy(func(error) {})
+	<-done
+}
+
+func TestOnUnhealthyNopStartsNoGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	l := NewNop()
+	l.OnUnhealthy(func(error) {})
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after OnUnhealthy on a Nop logger, want %d", after, before)
+	}
+}
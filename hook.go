@@ -0,0 +1,97 @@
+package logger
+
+import "fmt"
+
+// hookQueueSize is how many written entries can wait for their hooks.
+const hookQueueSize = 1024
+
+// hook is a callback registered with AddHook.
+type hook struct {
+	level LogLevel
+	fn    func(LogContent)
+}
+
+// AddHook registers fn to be called with every entry at or above level once
+// it has been written. Hooks run in order on a goroutine of their own, so
+// they may log through the same logger, but a hook must not call Close. A
+// slow hook doesn't delay writing; when more than 1024 written entries are
+// waiting for their hooks, later ones skip the hooks and the skip is reported
+// as an internal error. A panic in a hook is recovered and reported as an
+// internal error. The returned function removes the hook.
+func (l *Logger) AddHook(level LogLevel, fn func(LogContent)) (remove func()) {
+	h := &hook{level: level, fn: fn}
+
+	l.hooksMu.Lock()
+	// Copy on write, so the writer can use its snapshot without the lock
+	hooks := make([]*hook, len(l.hooks), len(l.hooks)+1)
+	copy(hooks, l.hooks)
+	l.hooks = append(hooks, h)
+	l.hooksMu.Unlock()
+
+	return func() {
+		l.hooksMu.Lock()
+		defer l.hooksMu.Unlock()
+
+		hooks := make([]*hook, 0, len(l.hooks))
+		for _, registered := range l.hooks {
+			if registered != h {
+				hooks = append(hooks, registered)
+			}
+		}
+		l.hooks = hooks
+	}
+}
+
+// loadHooks returns the registered hooks. The slice is never modified.
+func (l *Logger) loadHooks() []*hook {
+	l.hooksMu.Lock()
+	defer l.hooksMu.Unlock()
+	return l.hooks
+}
+
+// runHooks calls every hook registered for entry's level.
+func (l *Logger) runHooks(hooks []*hook, entry LogContent) {
+	for _, h := range hooks {
+		if entry.Level >= h.level {
+			l.runHook(h, entry)
+		}
+	}
+}
+
+// runHook calls h, recovering a panic so it can't stop the writer.
+func (l *Logger) runHook(h *hook, entry LogContent) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.reportError(fmt.Errorf("hook panicked: %v", r))
+		}
+	}()
+	h.fn(entry)
+}
+
+// hookCall is a written entry waiting for the hooks registered when it was
+// written.
+type hookCall struct {
+	hooks []*hook
+	entry LogContent
+}
+
+// runHookQueue calls the hooks of written entries, in order, until the
+// queue is closed.
+func (l *Logger) runHookQueue() {
+	defer close(l.hooksDone)
+	for call := range l.hookQueue {
+		l.runHooks(call.hooks, call.entry)
+	}
+}
+
+// queueHooks hands entry to the hook goroutine without blocking, so a hook
+// that logs can't stall the writer it waits on. It reports false when the
+// hooks are too far behind and entry was skipped.
+func (l *Logger) queueHooks(hooks []*hook, entry LogContent) bool {
+	select {
+	case l.hookQueue <- hookCall{hooks: hooks, entry: entry}:
+		return true
+	default:
+		return false
+	}
+}
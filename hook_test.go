@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHookSeesEntriesAtItsLevel(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	var seen []string
+	done := make(chan struct{}, 2)
+	l.AddHook(WARNING, func(entry LogContent) {
+		seen = append(seen, entry.Message)
+		done <- struct{}{}
+	})
+
+	l.Infof("skipped")
+	l.Warningf("warned")
+	l.Errorf("failed")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("hook not called")
+		}
+	}
+	if strings.Join(seen, ",") != "warned,failed" {
+		t.Errorf("hook saw %v, want [warned failed]", seen)
+	}
+}
+
+func TestHookPanicIsRecovered(t *testing.T) {
+	var reported atomic.Int32
+	l := newTestLogger(t, Config{InternalErrorHandler: func(error) { reported.Add(1) }})
+	l.AddHook(INFO, func(LogContent) { panic("boom") })
+
+	l.Infof("one")
+	l.Infof("two")
+	waitFor(t, "the panics to be reported", func() bool { return reported.Load() == 2 })
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush after a panicking hook: %v", err)
+	}
+}
+
+func TestHookCanLog(t *testing.T) {
+	l := newTestLogger(t, Config{QueueSize: 1})
+	var calls atomic.Int32
+	l.AddHook(WARNING, func(entry LogContent) {
+		calls.Add(1)
+		// Fill the queue from the hook; the writer must keep draining it
+		for i := 0; i < 10; i++ {
+			l.Infof("from hook %d", i)
+		}
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		for i := 0; i < 20; i++ {
+			l.Warningf("warning %d", i)
+		}
+		_ = l.Close()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("logging from a hook deadlocked")
+	}
+	if calls.Load() != 20 {
+		t.Errorf("hook called %d times, want 20", calls.Load())
+	}
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonKeys are the keys every JSON line starts with. Fields that would
+// collide with them are written with a "fields." prefix instead.
+var jsonKeys = map[string]bool{
+	"time":       true,
+	"level":      true,
+	"name":       true,
+	"category":   true,
+	"message":    true,
+	"caller":     true,
+	"stacktrace": true,
+	"events":     true,
+}
+
+// formatJSON renders entry as a single-line JSON object: time, level
+// (lowercase), name, category, message and caller when recorded, then events
+// for batches, the stack trace when captured and the entry's fields as
+// top-level keys in key order.
+func (l *Logger) formatJSON(entry LogContent) string {
+	var sb strings.Builder
+	sb.WriteString(`{"time":`)
+	if l.unixTime() {
+		sb.WriteString(l.formatTime(entry.Timestamp))
+	} else {
+		writeJSONValue(&sb, l.formatTime(entry.Timestamp))
+	}
+	sb.WriteString(`,"level":`)
+	writeJSONValue(&sb, strings.ToLower(entry.Level.String()))
+	sb.WriteString(`,"name":`)
+	writeJSONValue(&sb, l.name)
+	sb.WriteString(`,"category":`)
+	writeJSONValue(&sb, l.category)
+	sb.WriteString(`,"message":`)
+	writeJSONValue(&sb, entry.Message)
+	if entry.Caller != "" {
+		sb.WriteString(`,"` + CallerField + `":`)
+		writeJSONValue(&sb, entry.Caller)
+	}
+	if len(entry.Events) > 0 {
+		sb.WriteString(`,"events":`)
+		writeJSONValue(&sb, entry.Events)
+	}
+	if entry.StackTrace != "" {
+		sb.WriteString(`,"` + StackTraceField + `":`)
+		writeJSONValue(&sb, entry.StackTrace)
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for key := range entry.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := key
+		if jsonKeys[key] {
+			name = "fields." + key
+		}
+		sb.WriteString(",")
+		writeJSONValue(&sb, name)
+		sb.WriteString(":")
+		writeJSONValue(&sb, jsonFieldValue(entry.Fields[key]))
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// jsonFieldValue converts field values whose natural JSON form isn't useful:
+// errors become their message and type, durations a number of seconds.
+// ByteSize is written as its plain number by encoding/json already.
+func jsonFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case redactedError:
+		return struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		}{v.message, v.typeName}
+	case error:
+		return struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		}{v.Error(), fmt.Sprintf("%T", v)}
+	case time.Duration:
+		return v.Seconds()
+	default:
+		return value
+	}
+}
+
+// writeJSONValue writes value as JSON, falling back to its %v string when it
+// can't be marshalled.
+func writeJSONValue(sb *strings.Builder, value interface{}) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	sb.Write(encoded)
+}
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaVersionField(t *testing.T) {
+	for _, field := range []string{"", "schema_version", "v"} {
+		l := newTestLogger(t, Config{Format: "json", SchemaVersionField: field})
+		var buf syncBuffer
+		l.SetOutput(&buf)
+		l.Infof("versioned")
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(buf.String()), &line); err != nil {
+			t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+		}
+		if field == "" {
+			if _, ok := line["schema_version"]; ok {
+				t.Errorf("schema version written while off: %v", line)
+			}
+			continue
+		}
+		if line[field] != float64(SchemaVersion) {
+			t.Errorf("%s = %v, want %d", field, line[field], SchemaVersion)
+		}
+	}
+}
+
+func TestJSONLine(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "json"})
+	var buf syncBuffer
+	l.SetOutput(&buf)
+	message := "say \"hi\"\nthen <leave> \\ done"
+	l.Warningf("%s", message)
+	l.WithFields(map[string]interface{}{"message": "collides", "user": 7}).Infof("fields")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q, want one JSON object per entry", lines)
+	}
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	if first["level"] != "warning" || first["name"] != "test" || first["category"] != "app" || first["message"] != message {
+		t.Errorf("line = %v", first)
+	}
+	if _, err := time.Parse(time.RFC3339, first["time"].(string)); err != nil {
+		t.Errorf("time %q: %v", first["time"], err)
+	}
+	if !strings.HasPrefix(lines[0], `{"time":`) {
+		t.Errorf("line %q doesn't start with the time", lines[0])
+	}
+
+	var second map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[1], err)
+	}
+	if second["message"] != "fields" || second["fields.message"] != "collides" || second["user"] != float64(7) {
+		t.Errorf("line = %v, want colliding fields prefixed", second)
+	}
+}
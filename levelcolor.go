@@ -0,0 +1,62 @@
+package logger
+
+import "fmt"
+
+// colorCodes maps the colour names accepted by LevelColors to their ANSI SGR
+// codes.
+var colorCodes = map[string]int{
+	"reset":     0,
+	"black":     30,
+	"red":       31,
+	"green":     32,
+	"yellow":    33,
+	"blue":      34,
+	"magenta":   35,
+	"cyan":      36,
+	"white":     37,
+	"hiblack":   90,
+	"hired":     91,
+	"higreen":   92,
+	"hiyellow":  93,
+	"hiblue":    94,
+	"himagenta": 95,
+	"hicyan":    96,
+	"hiwhite":   97,
+}
+
+// defaultLevelColors are the SGR codes console lines are coloured with per
+// level when LevelColors doesn't say otherwise.
+var defaultLevelColors = [FATAL + 1]int{
+	DEBUG:   34,
+	INFO:    0,
+	JEDI:    32,
+	WARNING: 33,
+	ERROR:   31,
+	FATAL:   31,
+}
+
+// parseLevelColors returns the default level colours with the overrides, keyed
+// by level name, applied.
+func parseLevelColors(overrides map[string]string) ([FATAL + 1]int, error) {
+	colors := defaultLevelColors
+	for name, colorName := range overrides {
+		level, ok := levelMapping[name]
+		if !ok {
+			return colors, fmt.Errorf("invalid level color: unknown level %s", name)
+		}
+		code, ok := colorCodes[colorName]
+		if !ok {
+			return colors, fmt.Errorf("invalid level color: unknown color %s", colorName)
+		}
+		colors[level] = code
+	}
+	return colors, nil
+}
+
+// paintLevel returns s in the colour of level.
+func (l *Logger) paintLevel(level LogLevel, s string) string {
+	if level < DEBUG || level > FATAL {
+		return s
+	}
+	return paint(l.levelColors[level], s)
+}
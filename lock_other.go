@@ -0,0 +1,11 @@
+//go:build !unix
+
+package logger
+
+import "os"
+
+// lockCategory is a no-op on platforms without flock; LockCategory offers no
+// protection there.
+func lockCategory(dir string, dirMode, fileMode os.FileMode) (*os.File, error) {
+	return nil, nil
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockCategory takes a non-blocking exclusive flock on dir/.lock. The lock is
+// released when the returned file is closed or the process exits.
+func lockCategory(dir string, dirMode, fileMode os.FileMode) (*os.File, error) {
+	err := os.MkdirAll(dir, dirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, fileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrCategoryLocked
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
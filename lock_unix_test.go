@@ -0,0 +1,31 @@
+//go:build unix
+
+package logger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLockCategoryRejectsSecondLogger(t *testing.T) {
+	dir := t.TempDir()
+	first, err := New("first", dir, "app", Config{LockCategory: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	_, err = New("second", dir, "app", Config{LockCategory: true})
+	if !errors.Is(err, ErrCategoryLocked) {
+		t.Fatalf("second New error = %v, want ErrCategoryLocked", err)
+	}
+
+	// Closing the first logger releases the lock
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	second, err := New("second", dir, "app", Config{LockCategory: true})
+	if err != nil {
+		t.Fatalf("New after Close: %v", err)
+	}
+	_ = second.Close()
+}
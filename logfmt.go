@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// logfmtKeys are the keys every logfmt line starts with. Fields that would
+// collide with them are written with a "fields." prefix instead.
+var logfmtKeys = map[string]bool{
+	"time":       true,
+	"level":      true,
+	"name":       true,
+	"category":   true,
+	"msg":        true,
+	"caller":     true,
+	"events":     true,
+	"stacktrace": true,
+}
+
+// formatLogfmt renders entry as a single logfmt line: time, level
+// (lowercase), name, category, msg and caller when recorded, then events for
+// batches joined by newlines, the stack trace when captured and the entry's
+// fields in key order. Values are quoted when they need to be.
+func (l *Logger) formatLogfmt(entry LogContent) string {
+	var sb strings.Builder
+	writeLogfmtPair(&sb, "time", l.formatTime(entry.Timestamp))
+	writeLogfmtPair(&sb, "level", strings.ToLower(entry.Level.String()))
+	writeLogfmtPair(&sb, "name", l.name)
+	writeLogfmtPair(&sb, "category", l.category)
+	writeLogfmtPair(&sb, "msg", entry.Message)
+	if entry.Caller != "" {
+		writeLogfmtPair(&sb, CallerField, entry.Caller)
+	}
+	if len(entry.Events) > 0 {
+		writeLogfmtPair(&sb, "events", strings.Join(entry.Events, "\n"))
+	}
+	if entry.StackTrace != "" {
+		writeLogfmtPair(&sb, StackTraceField, entry.StackTrace)
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for key := range entry.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := logfmtKey(key)
+		if logfmtKeys[name] {
+			name = "fields." + name
+		}
+		writeLogfmtPair(&sb, name, logfmtFieldValue(entry.Fields[key]))
+	}
+
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// writeLogfmtPair writes key=value, separated from any previous pair by a
+// space.
+func writeLogfmtPair(sb *strings.Builder, key, value string) {
+	if sb.Len() > 0 {
+		sb.WriteString(" ")
+	}
+	sb.WriteString(key)
+	sb.WriteString("=")
+	if logfmtNeedsQuote(value) {
+		sb.WriteString(strconv.Quote(value))
+	} else {
+		sb.WriteString(value)
+	}
+}
+
+// logfmtFieldValue renders a field value as text. ByteSize is written as its
+// plain number, like in JSON.
+func logfmtFieldValue(value interface{}) string {
+	if size, ok := value.(ByteSize); ok {
+		return strconv.FormatInt(int64(size), 10)
+	}
+	return fmt.Sprint(value)
+}
+
+// logfmtKey replaces the characters a logfmt key can't hold with
+// underscores.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtNeedsQuote reports whether value must be quoted: when it is empty
+// or holds spaces, '=', quotes, control characters or invalid UTF-8.
+func logfmtNeedsQuote(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseLogfmt splits a logfmt line back into its key/value pairs.
+func parseLogfmt(t *testing.T, line string) map[string]string {
+	t.Helper()
+	pairs := make(map[string]string)
+	for line != "" {
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			t.Fatalf("no key=value at %q", line)
+		}
+		key, rest := line[:eq], line[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				t.Fatalf("bad quoting at %q: %v", rest, err)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		if _, dup := pairs[key]; dup {
+			t.Fatalf("key %s repeated", key)
+		}
+		pairs[key] = value
+		line = strings.TrimPrefix(rest, " ")
+	}
+	return pairs
+}
+
+func TestLogfmtRoundTrip(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "logfmt"})
+	var buf syncBuffer
+	l.SetOutput(&buf)
+	message := "said \"hi\" to a=b\nthen left\\"
+	l.WithFields(map[string]interface{}{
+		"user":    "Ada Lovelace",
+		"count":   3,
+		"empty":   "",
+		"msg":     "clash",
+		"bad key": "tab\there",
+	}).Warningf("%s", message)
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	output := buf.String()
+	if strings.Count(output, "\n") != 1 || !strings.HasSuffix(output, "\n") {
+		t.Fatalf("output %q isn't a single line", output)
+	}
+	got := parseLogfmt(t, strings.TrimSuffix(output, "\n"))
+	want := map[string]string{
+		"level":      "warning",
+		"name":       "test",
+		"category":   "app",
+		"msg":        message,
+		"user":       "Ada Lovelace",
+		"count":      "3",
+		"empty":      "",
+		"fields.msg": "clash",
+		"bad_key":    "tab\there",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+	if got["time"] == "" {
+		t.Error("time missing")
+	}
+	if len(got) != len(want)+1 {
+		t.Errorf("pairs = %q, want only the expected keys", got)
+	}
+}
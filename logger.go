@@ -1,11 +1,16 @@
 package logger
 
 import (
+	"bufio"
 	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/fatih/color"
+	"github.com/imkiptoo/logger/encrypt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -14,42 +19,511 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
+// Config controls how a Logger filters, writes and rotates its output.
+//
+// A category directory (path/category) is meant to be owned by a single
+// process. Several processes writing the same category are not supported:
+// give each process its own category, or set LockCategory so a second
+// process fails at construction instead of interleaving file indexes.
 type Config struct {
 	Level     string
 	Frequency string
 	Console   bool
-	MaxSize   string
-	Compress  bool
+	// MaxSize rotates the file once it reaches this size, e.g. "16MB". Empty
+	// or "0" means no size limit, leaving rotation to Frequency.
+	MaxSize  string
+	Compress bool
+	// LockCategory takes an advisory lock on the category directory for the
+	// lifetime of the process. New returns ErrCategoryLocked when another
+	// process already holds it.
+	LockCategory bool
+	// InternalErrorHandler receives the logger's own diagnostics (failed
+	// rotations, compressions, writes). When nil they are printed through the
+	// standard library log package, prefixed with "logger: ".
+	InternalErrorHandler func(error)
+	// RotateEveryNLines rotates the file after this many lines have been
+	// written to it by this process, regardless of its size. Meant for tests
+	// and batch jobs that want predictable file boundaries; 0 disables it.
+	RotateEveryNLines int
+	// ReopenCheckInterval makes the writer check, at most this often, whether
+	// the active file was removed or replaced on disk (e.g. by an operator's
+	// rm) and reopen it by name so later lines aren't written to an unlinked
+	// inode. 0 disables the check.
+	ReopenCheckInterval time.Duration
+	// FixedWidthTime rewrites trailing-zero-trimming fractional seconds in
+	// the timestamp layout (".999") to their zero-padded form (".000") so
+	// every timestamp has the same width and console columns stay aligned.
+	FixedWidthTime bool
+	// CompressAttempts is how many times a file is tried before compression
+	// is given up on, with exponential backoff between tries (default 3).
+	CompressAttempts int
+	// CompressFailureThreshold is the number of consecutive failed
+	// compressions after which the logger reports itself unhealthy and logs
+	// a WARNING (default 3).
+	CompressFailureThreshold int
+	// Format selects how lines are rendered. "text" (the default) is used for
+	// every output. "pretty" renders console output as aligned, coloured
+	// columns for local development; files still receive plain text. "lnav"
+	// writes space-delimited lines with level names lnav recognises (JEDI
+	// is written as NOTICE); see the README for the matching lnav format.
+	// "json" writes one JSON object per line with time, level, name,
+	// category and message keys followed by the entry's fields. "logfmt"
+	// writes one line of key=value pairs in the same order, with the message
+	// under msg, quoting values that need it.
+	Format string
+	// DebugSample lets this fraction (0 to 1) of DEBUG lines through when the
+	// level would otherwise filter them out, keeping a trickle of debug
+	// output in production. It can be changed later with SetDebugSample.
+	DebugSample float64
+	// LevelFilter decides how JEDI compares against the other levels.
+	//
+	// "ordered" (the default) treats levels as strictly ordered, with JEDI
+	// between INFO and WARNING:
+	//
+	//	debug:   DEBUG INFO JEDI WARNING ERROR FATAL
+	//	info:    INFO JEDI WARNING ERROR FATAL
+	//	jedi:    JEDI WARNING ERROR FATAL
+	//	warning: WARNING ERROR FATAL
+	//
+	// "grouped" gives JEDI the same severity as INFO, so a threshold of
+	// either shows both:
+	//
+	//	info, jedi: INFO JEDI WARNING ERROR FATAL
+	LevelFilter string
+	// StartupIndex picks the file a new process starts writing to:
+	//
+	//	"reuse" (default) appends to the highest-numbered file of the current
+	//	period while it is under MaxSize. Fewest files, but two processes
+	//	restarting together may both continue the same file.
+	//
+	//	"new" always starts the next index. A run never appends to a previous
+	//	run's file, at the cost of small files on frequent restarts.
+	//
+	//	"timestamp" starts the next index and suffixes every file name with the
+	//	process start time (1-20060102T150405.log), so processes starting
+	//	concurrently can't collide and each run's files are identifiable.
+	StartupIndex string
+	// MaxUncompressedBackups keeps at most this many finalized .log files
+	// (the active file is never counted or removed); 0 keeps all of them.
+	MaxUncompressedBackups int
+	// MaxCompressedBackups keeps at most this many .log.gz or .log.zst
+	// archives across all dated directories; 0 keeps all of them. Both
+	// limits are enforced independently after every rotation and after
+	// deferred compression, removing the oldest files first.
+	MaxCompressedBackups int
+	// MaxBackups is another name for MaxCompressedBackups, used when that
+	// is 0.
+	MaxBackups int
+	// MaxAge removes dated directories, with everything in them, once their
+	// whole period is older than this age, e.g. "30d", "12h" or "1w". The
+	// active directory is never removed. Checked on startup and after every
+	// rotation; empty keeps directories forever.
+	MaxAge string
+	// OverflowPolicy decides what logging calls do when the queue is full:
+	// "block" (the default) waits for room, "drop" discards the new entry and
+	// "dropOldest" discards the oldest queued entry to make room. Pending
+	// Flush, Rotate and SetOutput calls are never discarded; when one is the
+	// oldest, the new entry is dropped instead and the call may run after
+	// entries queued behind it. Dropped entries are counted by Dropped and
+	// reported in a notice.
+	OverflowPolicy string
+	// QueueSize is how many entries can wait for the writer (default 1024;
+	// zero or negative values use the default). A larger queue absorbs
+	// longer bursts or slow disks before OverflowPolicy applies, at the cost
+	// of memory and of more lines lost if the process dies without Close.
+	QueueSize int
+	// NoticeLevel is the level of the notices the logger writes about itself
+	// (dropped entries, repeated compression failures). When empty they are
+	// written as WARNING regardless of Level, so the signals aren't silenced
+	// by the level configuration causing them; when set they are written at
+	// that level and filtered like any other entry. Write errors can't be
+	// recorded in the file that failed and go to InternalErrorHandler only.
+	NoticeLevel string
+	// Fields are attached to every line the logger writes, e.g. service, env
+	// or version. Fields added with an Entry extend them and take precedence
+	// on duplicate keys.
+	Fields map[string]string
+	// WriteTimeout abandons a console or file write that takes longer than
+	// this, so a hung sink (a stalled FIFO or network mount) can't wedge the
+	// logger. Writers supporting SetWriteDeadline use it; others are written
+	// from a watchdog goroutine, and while an abandoned write is still
+	// blocked later writes to that sink are skipped. Each abandoned entry is
+	// counted in Stats().Dropped and marks the logger unhealthy until a write
+	// succeeds again. 0 disables the timeout.
+	WriteTimeout time.Duration
+	// CompressWindow defers compression of rotated files to a daily window
+	// of local time, "HH:MM-HH:MM" (e.g. "01:00-05:00", or "22:00-04:00"
+	// across midnight), so CPU-sensitive services don't gzip during peak
+	// hours. Rotated files are queued and stay readable, and subject to
+	// retention, until the window opens. Empty compresses immediately.
+	CompressWindow string
+	// EncryptionKey, when set, encrypts log files at rest with AES-GCM under
+	// this 16, 24 or 32 byte key. Files are named N.log.enc instead of N.log,
+	// are not compressed (ciphertext doesn't shrink), and can be read back
+	// with encrypt.OpenEncrypted.
+	EncryptionKey []byte
+	// InvalidUTF8 controls how invalid UTF-8 in messages and batch events is
+	// written: "keep" (the default) writes the bytes as they are, "replace"
+	// substitutes U+FFFD for each invalid byte and "escape" writes it as \xNN.
+	InvalidUTF8 string
+	// SchemaVersionField, when set (e.g. "schema_version" or "v"), adds a
+	// field of that name carrying SchemaVersion to every line so parsers of
+	// structured output can detect layout changes. Off by default.
+	SchemaVersionField string
+	// SummaryLevel, when set (e.g. "info"), makes Close write a final line at
+	// that level summarizing the run: how long the logger was open, the lines
+	// written per level, the bytes written to the file and the entries
+	// dropped. Off by default.
+	SummaryLevel string
+	// ShowName prefixes every message with the logger name. It is turned on
+	// automatically for loggers of this process that write to the same
+	// category directory.
+	ShowName bool
+	// FileFlushInterval, when set, buffers writes to the log file and flushes
+	// them at this interval, on rotation and on Close, trading a window of
+	// unwritten lines on a crash for fewer write calls. Console output is
+	// always written immediately. The buffer holds BufferSize bytes. Zero
+	// writes every batch straight through.
+	FileFlushInterval time.Duration
+	// ConsoleStream selects where Console output goes: "stdout" (the
+	// default) or "stderr", which keeps stdout free for program output.
+	ConsoleStream string
+	// ErrorStackLevel is the lowest level at which an error that formats
+	// itself with %+v (e.g. one from github.com/pkg/errors) has that verbose
+	// form, usually its stack trace, attached as the stack field. Defaults to
+	// "error"; "off" disables it.
+	ErrorStackLevel string
+	// AddCaller records the file:line each entry was logged from, shown after
+	// the message in text formats and as the caller key in JSON.
+	AddCaller bool
+	// StackTraceLevel is the lowest level at which the goroutine stack of
+	// the logging call is captured and written below the message, or as the
+	// stacktrace key in JSON. Empty disables it.
+	StackTraceLevel string
+	// SeparateErrorLog also writes ERROR and FATAL entries to error.log in
+	// the dated directory, created by the period's first such entry. The
+	// error log follows the main file's periods and is compressed with it,
+	// but is never rotated by size or line count.
+	SeparateErrorLog bool
+	// TimeFormat is the Go layout of each line's timestamp, or "unix" or
+	// "unixmilli" for seconds or milliseconds since the epoch. Defaults to
+	// "2006-01-02T15:04:05.000Z07:00".
+	TimeFormat string
+	// UTC writes timestamps in UTC rather than local time.
+	UTC bool
+	// Template lays out text lines from the placeholders {time}, {level},
+	// {name}, {category}, {message}, {caller}, {fields}, {hostname}, {pid}
+	// and {process}, e.g. "{time} {level} {name}: {message} {fields}". It
+	// replaces the text layout, not the lnav or JSON ones; empty keeps the
+	// built-in layout.
+	Template string
+	// ForceColor colours Console output even when it isn't a terminal or
+	// NO_COLOR is set. By default colour is used only on a terminal.
+	ForceColor bool
+	// NoColor writes Console output without colour, even on a terminal. It
+	// takes precedence over ForceColor.
+	NoColor bool
+	// LevelColors overrides the console colour of levels, keyed by level
+	// name, e.g. {"error": "magenta"}. Colours are black, red, green, yellow,
+	// blue, magenta, cyan and white, their bright "hi" forms (e.g. "hired")
+	// and "reset" for the terminal's default.
+	LevelColors map[string]string
+	// CompressLevel is the gzip level archives are written with, from 1
+	// (fastest) to 9 (smallest), or -2 for Huffman-only. 0 and values out of
+	// range select gzip's default.
+	CompressLevel int
+	// CompressCodec selects how archives are compressed: "gzip" (the
+	// default, .gz) or "zstd" (.zst), which is much cheaper on CPU at a
+	// similar ratio. Archives of either kind are recognised whichever is
+	// configured.
+	CompressCodec string
+	// ProcessFields adds the host name, PID and executable name to every
+	// entry as the hostname, pid and process fields. They are also available
+	// to Template as {hostname}, {pid} and {process} either way.
+	ProcessFields bool
+	// FileMode and DirMode are the octal permissions log files and
+	// directories are created with, e.g. "0640" and "0750". They default to
+	// "0644" and "0755"; the umask still applies.
+	FileMode string
+	DirMode  string
+	// CurrentLink maintains a current.log symlink in the category directory
+	// pointing at the active file, so `tail -F path/category/current.log`
+	// follows the log across rotation. Where symlinks can't be created the
+	// failure is reported once and logging carries on without it.
+	CurrentLink bool
+	// FallbackToStdout writes to stdout when the log file can't be created
+	// at startup, reporting the error as an internal error. By default New
+	// returns the error instead.
+	FallbackToStdout bool
+	// OnWriteError decides what happens to lines the log file write fails
+	// for, e.g. when the disk is full: "drop" (the default) reports the error
+	// and loses them, "retry" tries again a few times with a growing backoff
+	// first, and "stderr" writes them to stderr instead. A line partly
+	// written before a failed attempt may appear twice with "retry".
+	OnWriteError string
+	// MaxMessageLength cuts formatted messages longer than this many bytes,
+	// without splitting a UTF-8 character, and marks them with
+	// "…(truncated N bytes)". Fields are not affected. 0 disables it.
+	MaxMessageLength int
+	// Redactions are regular expressions whose matches in messages, field
+	// values and stack traces are replaced with "***" before the entry is
+	// queued, e.g. card numbers or tokens. Errors and other values are
+	// masked as the text they print as. Batch events are masked too. An
+	// invalid pattern makes New fail.
+	Redactions []string
+	// DedupWindow collapses identical messages at the same level logged
+	// within this window of the first: the first is written straight away
+	// and, if more followed, a single "(repeated N times in T)" line when the
+	// window closes. Up to 1024 distinct messages are tracked at once; others
+	// are written as usual. 0 disables it.
+	DedupWindow time.Duration
+	// Sampling keeps only 1 in N lines logged at a level, keyed by level
+	// name, e.g. {"debug": 100}. Lines are counted rather than picked at
+	// random, so the first of every N is kept. Levels not listed keep every
+	// line. Sampled-out lines are counted in Stats().Sampled.
+	Sampling map[string]int
+	// BufferSize is the size in bytes of the buffer writes to the log file go
+	// through when buffering (default 4096). Setting it enables buffering
+	// like FileFlushInterval, flushing every second unless that is set.
+	BufferSize int
+	// FatalExitCode is the status Fatalf exits the process with (default 1).
+	FatalExitCode int
+	// FatalNoExit makes Fatalf return once the fatal line is synced to disk
+	// instead of exiting, leaving shutdown to the application.
+	FatalNoExit bool
 }
 
+// ErrWriteTimeout is reported when a write exceeds Config.WriteTimeout.
+var ErrWriteTimeout = errors.New("logger: write timed out")
+
+// ErrCategoryLocked is returned by New when LockCategory is set and the
+// category directory is locked by another process.
+var ErrCategoryLocked = errors.New("logger: category directory is locked by another process")
+
+// SchemaVersion is the version of the line layout written under
+// SchemaVersionField. It is bumped whenever the layout of structured output
+// changes.
+const SchemaVersion = 1
+
+// defaultQueueSize is the capacity of the queue when QueueSize is unset.
+const defaultQueueSize = 1024
+
+// writeBatchSize is the most entries the writer takes off the queue at once.
+const writeBatchSize = 256
+
+// defaultTimeFormat is the layout used for the timestamp of each line.
+const defaultTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// fractionalSecondsPattern matches the ".999"/",999" layout elements, which
+// drop trailing zeros and so produce variable-width timestamps.
+var fractionalSecondsPattern = regexp.MustCompile(`[.,]9+`)
+
+const (
+	defaultCompressAttempts         = 3
+	defaultCompressFailureThreshold = 3
+	compressRetryBackoff            = 50 * time.Millisecond
+)
+
+const (
+	writeRetryAttempts = 3
+	writeRetryBackoff  = 50 * time.Millisecond
+)
+
+const (
+	defaultBufferSize    = 4096
+	defaultFlushInterval = time.Second
+)
+
+// logFilePattern matches log file names: the index, an optional startup
+// timestamp suffix, and an optional .gz or .zst extension for archives or
+// .enc for encrypted files.
+var logFilePattern = regexp.MustCompile(`^(\d+)(-\d{8}T\d{6})?\.log(\.gz|\.zst|\.enc)?$`)
+
+// fileSuffixFormat is the layout of the StartupIndex "timestamp" suffix.
+const fileSuffixFormat = "20060102T150405"
+
+// maxCreateAttempts bounds how many fresh indexes openLogFile tries when
+// another writer keeps claiming the index it picked.
+const maxCreateAttempts = 16
+
 type Logger struct {
-	name           string
-	category       string
-	path           string
-	level          LogLevel
-	rollFrequency  RollFrequency
-	mu             sync.Mutex
+	name          string
+	category      string
+	path          string
+	level         atomic.Int32
+	rollFrequency RollFrequency
+	mu            sync.Mutex
+	// outMu serializes writes to the outputs. The writer goroutine owns
+	// them, but notices from the compression goroutines are written from
+	// their own, and the encrypter doesn't tolerate concurrent writes.
+	// The outputs are only swapped with mu held, by the writer or while the
+	// writer is stopped.
+	outMu          sync.Mutex
 	compressMu     sync.Mutex
 	out            io.Writer
+	console        io.Writer
+	consoleWidth   int
+	colorize       bool
 	file           *os.File
 	maxSize        int64
+	maxAge         time.Duration
 	config         *Config
 	fileIndex      int
 	lastRotateTime time.Time
+	nextRotateTime time.Time
+	periodClosed   bool
 	fileWriter     *FileWriter
-	logQueue       chan LogContent
+	errorWriter    *FileWriter
+	customOutput   bool
+	queue          *entryQueue
+	draining       atomic.Bool
+	pending        []LogContent
+	done           chan struct{}
+	quit           chan struct{}
+	lockFile       *os.File
+	linesWritten   int
+	lastReopenTime time.Time
+	timeLayout     string
+	groupJedi      bool
+	fileSuffix     string
+	noticeLevel    LogLevel
+	noticeGated    bool
+	fields         map[string]interface{}
+	disabled       atomic.Bool
+	debugSample    atomic.Uint64
+	compressor     func(inputPath, outputPath string, level int) error
+
+	compressFailures            atomic.Uint64
+	consecutiveCompressFailures int
+	dropped                     atomic.Uint64
+	stalledWrites               sync.Map
+	onceKeys                    sync.Map
+	clock                       func() time.Time
+	compressWindow              *compressWindow
+	deferredCompress            []deferredFile
+	// compressQueue holds rotated files for the compressor goroutine,
+	// which compressWake wakes; both are guarded by mu
+	compressQueue  []deferredFile
+	compressWake   chan struct{}
+	compressorDone chan struct{}
+	healthMu       sync.Mutex
+	healthErrs     map[string]error
+	healthNotifier sync.Once
+	healthChanged  chan struct{}
+	unhealthyFn    func(error)
+	batchBuf       []byte
+	levelCounts    [FATAL + 1]atomic.Uint64
+	bytesWritten   atomic.Uint64
+	rotations      atomic.Uint64
+	// overflowed counts queue overflow drops not yet reported in a notice
+	overflowed      atomic.Uint64
+	started         time.Time
+	summaryLevel    LogLevel
+	summary         bool
+	showName        atomic.Bool
+	errorStackLevel LogLevel
+	errorStacks     bool
+	stackTraceLevel LogLevel
+	stackTraces     bool
+	hooksMu         sync.Mutex
+	hooks           []*hook
+	// hooked holds the entries of a batch waiting for their hooks
+	hooked []LogContent
+	// hookQueue feeds runHookQueue, which closes hooksDone when it returns
+	hookQueue chan hookCall
+	hooksDone chan struct{}
+	// template is the parsed Template, nil when none is set
+	template      lineTemplate
+	levelColors   [FATAL + 1]int
+	compressLevel int
+	archiveExt    string
+	process       processInfo
+	// writers are the extra sinks added with AddWriter, guarded by outMu
+	writers []io.Writer
+	// extractors are the functions added with AddContextExtractor
+	extractorsMu sync.Mutex
+	extractors   []func(context.Context) map[string]interface{}
+	fileMode     os.FileMode
+	dirMode      os.FileMode
+	// currentLinkFailed stops CurrentLink after the symlink couldn't be made
+	currentLinkFailed bool
+	// archiveMu keeps Compress, Purge and the compressor from working on
+	// the same file at once. It is taken before mu.
+	archiveMu sync.Mutex
+	events    chan Event
+	// writeFailures counts consecutive failed file writes
+	writeFailures atomic.Uint64
+	redactions    []*regexp.Regexp
+	// dedup is set when DedupWindow is
+	dedup *deduper
+	// sampleEvery is the Sampling ratio per level, 0 keeping every line
+	sampleEvery  [FATAL + 1]uint64
+	sampleCounts [FATAL + 1]atomic.Uint64
+	sampled      atomic.Uint64
+	// configFile is the file NewFromFile read, and fileConfig the settings
+	// from it that are in effect, for Reload
+	configFile string
+	fileConfig Config
+	// flushInterval is how often the buffered log file is flushed, 0 when
+	// writes aren't buffered
+	flushInterval time.Duration
+}
+
+// Stats is a snapshot of a Logger's counters.
+type Stats struct {
+	// CompressFailures counts files whose compression failed after all
+	// retries.
+	CompressFailures uint64
+	// Dropped counts entries that were not written, e.g. because the queue
+	// was full or a write exceeded WriteTimeout.
+	Dropped uint64
+	// Levels counts the lines written at each level.
+	Levels map[LogLevel]uint64
+	// Bytes counts the bytes written to the file output.
+	Bytes uint64
+	// Rotations counts the new files started by rotation.
+	Rotations uint64
+	// Sampled counts the lines discarded by Sampling.
+	Sampled uint64
 }
 
+// LogContent is a single queued log entry. Message holds the formatted
+// message only; the line layout is applied when the entry is written.
 type LogContent struct {
 	Level     LogLevel
 	Timestamp time.Time
 	Message   string
+	Fields    map[string]interface{}
+	// Events holds the grouped events of an entry logged with Batch.
+	Events []string
+	// Caller is the file:line the entry was logged from, set with AddCaller.
+	Caller string
+	// StackTrace is the stack of the logging goroutine, set at
+	// StackTraceLevel.
+	StackTrace string
+
+	// control marks instructions to the writer that travel through the
+	// queue, so they are handled in order with the entries around them.
+	control control
+	// done answers a caller waiting on a controlFlush or controlSetOutput.
+	done chan error
+	// output is the writer installed by a controlSetOutput.
+	output io.Writer
 }
 
 type LogLevel int
+
+// RollFrequency is how often a new dated directory is started. Directories
+// roll on the period boundary even when the file is below MaxSize, and are
+// named after the start of their period (for WEEKLY, the Monday). SECONDLY is
+// the finest granularity: a directory per sub-second window would flood the
+// category with directories, so size or RotateEveryNLines should be used to
+// split files within a second instead.
 type RollFrequency int
 
 const (
@@ -90,7 +564,9 @@ var rollFrequencyMapping = map[string]RollFrequency{
 	"yearly":   YEARLY,
 }
 
-func (level LogLevel) toString() string {
+// String returns the level's upper-case name as written in text output, e.g.
+// "WARNING", so LogLevel values print by name.
+func (level LogLevel) String() string {
 	switch level {
 	case DEBUG:
 		return "DEBUG"
@@ -109,6 +585,102 @@ func (level LogLevel) toString() string {
 	}
 }
 
+// fixedWidthLayout replaces ".999"-style fractional seconds in layout with
+// the zero-padded ".000" form of the same precision.
+func fixedWidthLayout(layout string) string {
+	return fractionalSecondsPattern.ReplaceAllStringFunc(layout, func(match string) string {
+		return match[:1] + strings.Repeat("0", len(match)-1)
+	})
+}
+
+// formatTime renders a line's timestamp with the configured layout, in UTC
+// when UTC is set.
+func (l *Logger) formatTime(t time.Time) string {
+	if l.config.UTC {
+		t = t.UTC()
+	}
+	switch l.timeLayout {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.Format(l.timeLayout)
+}
+
+// unixTime reports whether timestamps are written as epoch numbers.
+func (l *Logger) unixTime() bool {
+	return l.timeLayout == "unix" || l.timeLayout == "unixmilli"
+}
+
+// periodStart returns the start of the rotation period containing t. Weeks
+// start on Monday.
+func (l *Logger) periodStart(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch l.rollFrequency {
+	case SECONDLY:
+		return t.Truncate(time.Second)
+	case MINUTELY:
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, t.Location())
+	case HOURLY:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	case WEEKLY:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, t.Location())
+	case MONTHLY:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case YEARLY:
+		return time.Date(year, 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// nextPeriodStart returns the wall-clock boundary at which the period
+// containing t ends, e.g. the next top of the hour for HOURLY or the next
+// local midnight for DAILY. Boundaries are computed on the calendar rather
+// than by adding a fixed duration, so days that are 23 or 25 hours long across
+// DST changes still end at midnight.
+func (l *Logger) nextPeriodStart(t time.Time) time.Time {
+	start := l.periodStart(t)
+	year, month, day := start.Date()
+	switch l.rollFrequency {
+	case SECONDLY:
+		return start.Add(time.Second)
+	case MINUTELY:
+		return time.Date(year, month, day, start.Hour(), start.Minute()+1, 0, 0, start.Location())
+	case HOURLY:
+		next := time.Date(year, month, day, start.Hour()+1, 0, 0, 0, start.Location())
+		if !next.After(start) {
+			// The skipped hour when clocks go forward can normalize back
+			// to the start of the hour before it
+			next = start.Add(time.Hour)
+		}
+		return next
+	case WEEKLY:
+		return time.Date(year, month, day+7, 0, 0, 0, 0, start.Location())
+	case MONTHLY:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, start.Location())
+	case YEARLY:
+		return time.Date(year+1, 1, 1, 0, 0, 0, 0, start.Location())
+	default:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, start.Location())
+	}
+}
+
+// setRotateTime records t as the start of the active file's period and
+// schedules the next time-based rotation for the end of that period.
+func (l *Logger) setRotateTime(t time.Time) {
+	l.lastRotateTime = t
+	l.nextRotateTime = l.nextPeriodStart(t)
+}
+
+// periodDir returns the name of the dated directory for the period
+// containing t.
+func (l *Logger) periodDir(t time.Time) string {
+	return l.periodStart(t).Format(getDateFormat(l))
+}
+
 func getDateFormat(l *Logger) string {
 	switch l.rollFrequency {
 	case SECONDLY:
@@ -130,8 +702,16 @@ func getDateFormat(l *Logger) string {
 	}
 }
 
+// FileWriter writes to a log file and keeps an exact count of its size, so
+// rotation decisions don't need a stat per line.
 type FileWriter struct {
-	file *os.File
+	file      *os.File
+	encrypter *encrypt.Writer
+	// buf, when set, holds writes until Flush. mu guards it, as flushes come
+	// from a different goroutine than writes.
+	buf     *bufio.Writer
+	mu      sync.Mutex
+	written atomic.Int64
 }
 
 func getAbsolutePath(path string) string {
@@ -166,22 +746,87 @@ func NewFileWriter(filename string) (*FileWriter, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &FileWriter{file: file}, nil
+	return newFileWriter(file), nil
+}
+
+// newFileWriter wraps an open file, seeding the byte count with the file's
+// current size since it is opened for appending.
+func newFileWriter(file *os.File) *FileWriter {
+	fw := &FileWriter{file: file}
+	if fileInfo, err := file.Stat(); err == nil {
+		fw.written.Store(fileInfo.Size())
+	}
+	return fw
 }
 
 func (fw *FileWriter) Write(p []byte) (n int, err error) {
-	return fw.file.Write(p)
+	if fw.buf != nil {
+		fw.mu.Lock()
+		n, err = fw.buf.Write(p)
+		fw.mu.Unlock()
+	} else {
+		n, err = (*fileSink)(fw).Write(p)
+	}
+	fw.written.Add(int64(n))
+	return n, err
+}
+
+// Flush writes any buffered data to the file.
+func (fw *FileWriter) Flush() error {
+	if fw.buf == nil {
+		return nil
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.buf.Flush()
+}
+
+// Sync writes any buffered data and commits the file to stable storage.
+func (fw *FileWriter) Sync() error {
+	err := fw.Flush()
+	if err != nil {
+		return err
+	}
+	return fw.file.Sync()
+}
+
+// fileSink is where a FileWriter's bytes end up: the file, through the
+// encrypter when one is set. It counts the encryption overhead; the payload
+// is counted by FileWriter.Write as soon as it is accepted.
+type fileSink FileWriter
+
+func (s *fileSink) Write(p []byte) (int, error) {
+	if s.encrypter == nil {
+		return s.file.Write(p)
+	}
+
+	n, err := s.encrypter.Write(p)
+	if n > 0 {
+		s.written.Add(encrypt.Overhead)
+	}
+	return n, err
+}
+
+// Written returns the size of the file: the bytes it held when opened plus
+// everything written through this FileWriter since.
+func (fw *FileWriter) Written() int64 {
+	return fw.written.Load()
 }
 
 func (fw *FileWriter) Close() error {
-	return fw.file.Close()
+	err := fw.Flush()
+	if closeErr := fw.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 func New(name, path, category string, config Config) (*Logger, error) {
-	return newLogger(name, path, category, &config), nil
+	return newLogger(name, path, category, &config)
 }
 
-func newLogger(name, path, category string, config *Config) *Logger {
+func newLogger(name, path, category string, config *Config) (*Logger, error) {
 	level, ok := levelMapping[config.Level]
 	if !ok {
 		level = INFO
@@ -194,50 +839,269 @@ func newLogger(name, path, category string, config *Config) *Logger {
 	logger := &Logger{
 		name:           name,
 		category:       category,
-		level:          level,
 		path:           getAbsolutePath(path),
 		rollFrequency:  rollFrequency,
 		config:         config,
-		maxSize:        getBytesFromSizeString(config.MaxSize),
 		fileIndex:      1,
 		lastRotateTime: time.Now(),
-		logQueue:       make(chan LogContent, 1024),
+		started:        time.Now(),
+		done:           make(chan struct{}),
+		quit:           make(chan struct{}),
+		compressWake:   make(chan struct{}, 1),
+		compressorDone: make(chan struct{}),
+		hookQueue:      make(chan hookCall, hookQueueSize),
+		hooksDone:      make(chan struct{}),
+		events:         make(chan Event, eventBufferSize),
+		clock:          time.Now,
+		timeLayout:     defaultTimeFormat,
+		compressor:     compressFile,
+		groupJedi:      config.LevelFilter == "grouped",
+	}
+	logger.SetLevel(level)
+
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	logger.queue = newEntryQueue(queueSize)
+
+	logger.process = newProcessInfo()
+	if len(config.Fields) > 0 || config.SchemaVersionField != "" || config.ProcessFields {
+		logger.fields = make(map[string]interface{}, len(config.Fields)+4)
+		for key, value := range config.Fields {
+			logger.fields[key] = value
+		}
+		if config.SchemaVersionField != "" {
+			logger.fields[config.SchemaVersionField] = SchemaVersion
+		}
+		if config.ProcessFields {
+			logger.fields[HostnameField] = logger.process.hostname
+			logger.fields[PIDField] = os.Getpid()
+			logger.fields[ProcessField] = logger.process.name
+		}
+	}
+
+	logger.summaryLevel, logger.summary = levelMapping[config.SummaryLevel]
+
+	logger.errorStackLevel, logger.errorStacks = ERROR, true
+	if config.ErrorStackLevel == "off" {
+		logger.errorStacks = false
+	} else if stackLevel, ok := levelMapping[config.ErrorStackLevel]; ok {
+		logger.errorStackLevel = stackLevel
+	}
+	logger.stackTraceLevel, logger.stackTraces = levelMapping[config.StackTraceLevel]
+
+	logger.noticeLevel = WARNING
+	if config.NoticeLevel != "" {
+		noticeLevel, ok := levelMapping[config.NoticeLevel]
+		if ok {
+			logger.noticeLevel = noticeLevel
+			logger.noticeGated = true
+		}
+	}
+
+	if config.StartupIndex == "timestamp" {
+		logger.fileSuffix = "-" + logger.lastRotateTime.Format(fileSuffixFormat)
+	}
+
+	logger.SetDebugSample(config.DebugSample)
+
+	if config.TimeFormat != "" {
+		logger.timeLayout = config.TimeFormat
+	}
+	if config.FixedWidthTime {
+		logger.timeLayout = fixedWidthLayout(logger.timeLayout)
+	}
+
+	maxSize, err := parseMaxSize(config.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	logger.maxSize = maxSize
+
+	logger.compressLevel = config.CompressLevel
+	if logger.compressLevel == gzip.NoCompression || logger.compressLevel < gzip.HuffmanOnly || logger.compressLevel > gzip.BestCompression {
+		logger.compressLevel = gzip.DefaultCompression
+	}
+
+	logger.flushInterval = config.FileFlushInterval
+	if logger.flushInterval <= 0 && config.BufferSize > 0 {
+		logger.flushInterval = defaultFlushInterval
+	}
+
+	logger.archiveExt = ".gz"
+	switch config.CompressCodec {
+	case "", "gzip":
+	case "zstd":
+		logger.compressor = compressFileZstd
+		logger.archiveExt = ".zst"
+	default:
+		return nil, fmt.Errorf("invalid compress codec: %s", config.CompressCodec)
+	}
+
+	logger.fileMode, err = parseFileMode(config.FileMode, 0644)
+	if err != nil {
+		return nil, err
+	}
+	logger.dirMode, err = parseFileMode(config.DirMode, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.OnWriteError {
+	case "", "drop", "retry", "stderr":
+	default:
+		return nil, fmt.Errorf("invalid write error policy: %s", config.OnWriteError)
+	}
+
+	logger.redactions, err = compileRedactions(config.Redactions)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.sampleEvery, err = parseSampling(config.Sampling)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.levelColors, err = parseLevelColors(config.LevelColors)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Template != "" {
+		logger.template, err = parseTemplate(config.Template)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.EncryptionKey != nil {
+		err = encrypt.ValidateKey(config.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %w", err)
+		}
+	}
+
+	if config.MaxCompressedBackups == 0 {
+		config.MaxCompressedBackups = config.MaxBackups
+	}
+
+	if config.MaxAge != "" {
+		logger.maxAge, err = parseAge(config.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.CompressWindow != "" {
+		logger.compressWindow, err = parseCompressWindow(config.CompressWindow)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.LockCategory {
+		lockFile, err := lockCategory(filepath.Join(logger.path, logger.category), logger.dirMode, logger.fileMode)
+		if err != nil {
+			return nil, err
+		}
+		logger.lockFile = lockFile
+	}
+
+	logger.showName.Store(config.ShowName)
+	logger.joinCategoryDir()
+
+	err = logger.setOutput()
+	if err != nil {
+		logger.leaveCategoryDir()
+		if logger.lockFile != nil {
+			_ = logger.lockFile.Close()
+		}
+		return nil, err
 	}
-	logger.setOutput()
 
 	logger.mu.Lock()
-	err := compressUncompressedFilesOnStartup(logger)
+	err = compressUncompressedFilesOnStartup(logger)
 	if err != nil {
-		fmt.Printf("logger: %v\n", err)
+		logger.reportError(err)
 	}
 	logger.mu.Unlock()
 
+	logger.removeOldBackups()
+
 	go logger.startLogging()
+	go logger.runHookQueue()
+	go logger.runPeriodTimer()
+	go logger.runCompressor()
+	if logger.compressWindow != nil {
+		go logger.runCompressScheduler(compressWindowCheckInterval)
+	}
+	if logger.flushInterval > 0 {
+		go logger.runFileFlusher(logger.flushInterval)
+	}
+	if config.DedupWindow > 0 {
+		logger.dedup = newDeduper(config.DedupWindow)
+		go logger.runDedupFlusher(config.DedupWindow)
+	}
+
+	return logger, nil
+}
 
-	return logger
+// reportError hands an internal diagnostic to Config.InternalErrorHandler, or
+// to the standard library logger when no handler is configured.
+func (l *Logger) reportError(err error) {
+	if l.config.InternalErrorHandler != nil {
+		l.config.InternalErrorHandler(err)
+		return
+	}
+	log.Printf("logger: %v\n", err)
 }
 
-func (l *Logger) setOutput() {
+func (l *Logger) setOutput() error {
 	var fileWriter io.Writer
 	fileWriter, err := l.createFileWriter()
 	if err != nil {
-		log.Printf("logger: %v\n", err)
+		if !l.config.FallbackToStdout {
+			return fmt.Errorf("open log file: %w", err)
+		}
+		l.reportError(err)
 		fileWriter = os.Stdout
 	}
 
-	if l.config.Console {
-		l.out = io.MultiWriter(os.Stdout, fileWriter)
-	} else {
-		l.out = fileWriter
+	l.out = fileWriter
+	l.setConsole()
+	return nil
+}
+
+// setConsole sets up the console output from the config, removing it when
+// Console is off. Once the logger is running it must be called with l.outMu
+// held.
+func (l *Logger) setConsole() {
+	l.console = nil
+	l.colorize = false
+	if !l.config.Console {
+		return
+	}
+
+	stream := os.Stdout
+	if l.config.ConsoleStream == "stderr" {
+		stream = os.Stderr
+	}
+	l.console = stream
+	l.colorize = streamColor(stream) || l.config.ForceColor
+	if l.config.Format == "pretty" {
+		l.consoleWidth = terminalWidth(stream)
+	}
+	if l.config.Format == "json" || l.config.Format == "logfmt" || l.config.NoColor {
+		l.colorize = false
 	}
 }
 
 func (l *Logger) createFileWriter() (io.Writer, error) {
-	dateFormat := getDateFormat(l)
-
-	l.lastRotateTime = time.Now()
-	logDir := filepath.Join(l.path, l.category, l.lastRotateTime.Format(dateFormat))
-	err := os.MkdirAll(logDir, 0755)
+	l.setRotateTime(time.Now())
+	logDir := filepath.Join(l.path, l.category, l.periodDir(l.lastRotateTime))
+	err := os.MkdirAll(logDir, l.dirMode)
 	if err != nil {
 		return nil, err
 	}
@@ -249,7 +1113,6 @@ func (l *Logger) createFileWriter() (io.Writer, error) {
 	}
 
 	// Filter log files and find the highest index
-	logFilePattern := regexp.MustCompile(`^(\d+)\.log(\.gz)?$`)
 	maxIndex := 0
 	for _, file := range files {
 		if matches := logFilePattern.FindStringSubmatch(file.Name()); matches != nil {
@@ -260,199 +1123,297 @@ func (l *Logger) createFileWriter() (io.Writer, error) {
 		}
 	}
 
-	// Check if the current file has reached the maximum size
-	currentFile := filepath.Join(logDir, fmt.Sprintf("%d.log", maxIndex))
-	fileInfo, err := os.Stat(currentFile)
-	if err == nil && fileInfo.Size() < l.maxSize {
-		l.fileIndex = maxIndex
-	} else {
-		l.fileIndex = maxIndex + 1
+	// Reuse the current file if it hasn't reached the maximum size
+	var file *os.File
+	if l.config.StartupIndex == "" || l.config.StartupIndex == "reuse" {
+		currentFile := filepath.Join(logDir, l.logFileName(maxIndex))
+		fileInfo, err := os.Stat(currentFile)
+		if err == nil && (l.maxSize == 0 || fileInfo.Size() < l.maxSize) {
+			file, err = os.OpenFile(currentFile, os.O_APPEND|os.O_WRONLY, l.fileMode)
+			if err == nil {
+				l.fileIndex = maxIndex
+			}
+		}
 	}
 
-	// Create and open the new log file
-	filename := filepath.Join(logDir, fmt.Sprintf("%d.log", l.fileIndex))
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, err
+	// Otherwise create a new log file with a fresh index
+	if file == nil {
+		file, l.fileIndex, err = l.openLogFile(logDir, maxIndex+1)
+		if err != nil {
+			return nil, err
+		}
 	}
 	l.file = file
-	l.fileWriter = &FileWriter{file: file}
+	l.fileWriter = l.newFileWriter(file)
+	l.updateCurrentLink()
 	return l.fileWriter, nil
 }
 
-func (l *Logger) rotate() {
+// logFileName returns the name of the log file with the given index.
+func (l *Logger) logFileName(index int) string {
+	if l.config.EncryptionKey != nil {
+		return fmt.Sprintf("%d%s.log.enc", index, l.fileSuffix)
+	}
+	return fmt.Sprintf("%d%s.log", index, l.fileSuffix)
+}
+
+// newFileWriter wraps an open log file, encrypting it when an EncryptionKey
+// is configured.
+func (l *Logger) newFileWriter(file *os.File) *FileWriter {
+	fw := newFileWriter(file)
+	if l.config.EncryptionKey != nil {
+		// The key is validated in newLogger
+		fw.encrypter, _ = encrypt.NewWriter(file, l.config.EncryptionKey)
+	}
+	if l.flushInterval > 0 {
+		size := l.config.BufferSize
+		if size <= 0 {
+			size = defaultBufferSize
+		}
+		fw.buf = bufio.NewWriterSize((*fileSink)(fw), size)
+	}
+	return fw
+}
+
+// runFileFlusher flushes the buffered file every interval until the logger
+// is stopped.
+func (l *Logger) runFileFlusher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.quit:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			for _, fw := range []*FileWriter{l.fileWriter, l.errorWriter} {
+				if fw == nil {
+					continue
+				}
+				err := fw.Flush()
+				if err != nil {
+					l.reportError(fmt.Errorf("flush: %w", err))
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// openFileWriter opens filename for appending and wraps it like
+// newFileWriter.
+func (l *Logger) openFileWriter(filename string) (*FileWriter, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, l.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	return l.newFileWriter(file), nil
+}
+
+// openLogFile exclusively creates the log file for index in dir. If another
+// writer has already claimed that index (or archived it), the next one is
+// tried, so two processes racing on the same directory never append to the
+// same file.
+func (l *Logger) openLogFile(dir string, index int) (*os.File, int, error) {
+	for attempt := 0; attempt < maxCreateAttempts; attempt++ {
+		filename := filepath.Join(dir, l.logFileName(index))
+		if archived(filename) {
+			index++
+			continue
+		}
+		file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_APPEND|os.O_WRONLY, l.fileMode)
+		if err == nil {
+			return file, index, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, 0, err
+		}
+		index++
+	}
+	return nil, 0, fmt.Errorf("could not claim a log file index in %s after %d attempts", dir, maxCreateAttempts)
+}
+
+// rotate closes the active file and opens the next one, in a new dated
+// directory when the period has changed. The finished file is handed over
+// for compression: with the rest of its directory when the period changed,
+// or on its own when Compress is set. The next file is opened before the
+// active one is closed, so when that fails the error is returned and the
+// active file stays in use; the next batch tries again. It must be called on
+// the writer.
+func (l *Logger) rotate() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	dateSwitched := false
+	// closePeriod already compressed the previous directory
+	periodClosed := l.periodClosed
 
 	currentDate := time.Now()
 
-	dateFormat := getDateFormat(l)
-
-	previousDirName := filepath.Join(l.path, l.category, l.lastRotateTime.Format(dateFormat))
+	previousDirName := filepath.Join(l.path, l.category, l.periodDir(l.lastRotateTime))
 
 	// Check if the date has changed and reset the file index if necessary
-	if currentDate.Format(dateFormat) != l.lastRotateTime.Format(dateFormat) {
-		l.fileIndex = 1
-		dateSwitched = true
-	} else {
-		l.fileIndex++
-	}
-
-	// Only close the fileWriter if the date has changed, or it's a new log file
-	if l.fileWriter != nil && (currentDate.Format(dateFormat) != l.lastRotateTime.Format(dateFormat) || l.fileIndex > 1) {
-		_ = l.fileWriter.Close()
+	dateSwitched := l.periodDir(currentDate) != l.periodDir(l.lastRotateTime)
+	nextIndex := l.fileIndex + 1
+	if dateSwitched {
+		nextIndex = 1
 	}
 
-	l.lastRotateTime = currentDate
+	dirName := filepath.Join(l.path, l.category, l.periodDir(currentDate))
 
-	dirName := filepath.Join(l.path, l.category, l.lastRotateTime.Format(dateFormat))
-
-	filename := filepath.Join(dirName, fmt.Sprintf("%d.log", l.fileIndex))
-	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	err := os.MkdirAll(dirName, l.dirMode)
 	if err != nil {
-		log.Printf("logger: %v\n", err)
-		return
+		return err
 	}
-	fileWriter, err := NewFileWriter(filename)
+	file, fileIndex, err := l.openLogFile(dirName, nextIndex)
 	if err != nil {
-		log.Printf("logger: %v\n", err)
-		return
+		return err
 	}
+	fileWriter := l.newFileWriter(file)
 
-	l.fileWriter = fileWriter
-	if l.config.Console {
-		l.out = io.MultiWriter(os.Stdout, fileWriter)
-	} else {
-		l.out = fileWriter
+	finished := ""
+	if l.fileWriter != nil {
+		finished = l.fileWriter.file.Name()
+		err := l.fileWriter.Close()
+		if err != nil {
+			l.reportError(err)
+		}
+	}
+
+	l.setRotateTime(currentDate)
+
+	if finished != "" {
+		l.emit(Event{Type: Rotated, Path: finished})
 	}
 
+	l.fileIndex = fileIndex
+	l.fileWriter = fileWriter
+	l.linesWritten = 0
+	l.out = fileWriter
+	l.periodClosed = false
+	l.rotations.Add(1)
+
 	// Update the reference to the current log file
 	l.file = l.fileWriter.file
+	l.updateCurrentLink()
 
 	if dateSwitched {
+		// The next ERROR line opens the new period's error log
+		l.closeErrorLog()
+	}
+
+	if dateSwitched && !periodClosed {
 		// Compress all uncompressed files in the previous folder
-		err := compressPreviousUncompressedFiles(previousDirName)
+		err := l.compressPreviousUncompressedFiles(previousDirName)
 		if err != nil {
-			log.Printf("logger: %v\n", err)
+			l.reportError(err)
 		}
+	} else if finished != "" && l.config.Compress && l.config.EncryptionKey == nil {
+		l.compressOrDefer(finished, l.compressLevel)
 	}
+	return nil
 }
 
-func (l *Logger) compress() {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+func (l *Logger) compressPreviousUncompressedFiles(previousLogDir string) error {
+	files, err := os.ReadDir(previousLogDir)
+	if err != nil {
+		return err
+	}
 
-	if l.config.Compress {
-		if l.fileWriter != nil {
-			_ = l.fileWriter.Close()
+	for _, file := range files {
+		matches := logFilePattern.FindStringSubmatch(file.Name())
+		if (matches != nil && matches[3] == "") || file.Name() == errorLogName {
+			l.compressOrDefer(filepath.Join(previousLogDir, file.Name()), l.compressLevel)
 		}
+	}
 
-		dateFormat := getDateFormat(l)
-
-		previousFileIndex := l.fileIndex - 1
-		if previousFileIndex > 0 {
-			previousFilename := filepath.Join(l.path, l.category, l.lastRotateTime.Format(dateFormat), fmt.Sprintf("%d.log", previousFileIndex))
-			compressedFilename := previousFilename + ".gz"
+	return nil
+}
 
-			input, err := os.Open(previousFilename)
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-				return
-			}
+// archiveWithRetry compresses inputPath into an archive with the codec's
+// extension, leaving the original in place. Failed attempts are retried with
+// exponential backoff, except when the file no longer exists.
+func (l *Logger) archiveWithRetry(inputPath string, level int) error {
+	outputPath := inputPath + l.archiveExt
+	attempts := l.config.CompressAttempts
+	if attempts <= 0 {
+		attempts = defaultCompressAttempts
+	}
 
-			output, err := os.Create(compressedFilename)
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-				err := input.Close()
-				if err != nil {
-					log.Printf("logger: %v\n", err)
-				}
-				return
-			}
-
-			gw, err := gzip.NewWriterLevel(output, gzip.BestCompression)
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-			}
-
-			_, err = io.Copy(gw, input)
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-			}
-
-			// Close the input, output, and gzip.Writer before removing the file
-			err = input.Close()
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-			}
-			err = gw.Close()
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-			}
-			err = output.Close()
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-			}
-
-			err = os.Remove(previousFilename)
-			if err != nil {
-				log.Printf("logger: %v\n", err)
-			}
+	backoff := compressRetryBackoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = l.compressor(inputPath, outputPath, level)
+		if err == nil {
+			break
 		}
-
-		filename := filepath.Join(l.path, l.category, l.lastRotateTime.Format(dateFormat), fmt.Sprintf("%d.log", l.fileIndex))
-		fileWriter, err := NewFileWriter(filename)
-		if err != nil {
-			log.Printf("logger: %v\n", err)
-			return
+		if errors.Is(err, os.ErrNotExist) {
+			// Already archived or removed: any archive is not ours to delete
+			break
 		}
-		l.fileWriter = fileWriter
-		if l.config.Console {
-			l.out = io.MultiWriter(os.Stdout, fileWriter)
-		} else {
-			l.out = fileWriter
+		// Don't leave a partial archive next to the original
+		_ = os.Remove(outputPath)
+		if attempt < attempts {
+			time.Sleep(backoff)
+			backoff *= 2
 		}
 	}
+	return err
+}
+
+// emitCompressed sends the Compressed event for inputPath, unless it was
+// removed before it could be archived.
+func (l *Logger) emitCompressed(inputPath string, err error) {
+	switch {
+	case err == nil:
+		l.emit(Event{Type: Compressed, Path: inputPath + l.archiveExt})
+	case !errors.Is(err, os.ErrNotExist):
+		l.emit(Event{Type: Compressed, Path: inputPath, Err: err})
+	}
 }
 
-func compressPreviousUncompressedFiles(previousLogDir string) error {
-	files, err := os.ReadDir(previousLogDir)
+// recordCompress records the outcome of compressing inputPath. It must be
+// called with l.mu held.
+func (l *Logger) recordCompress(inputPath string, err error) {
 	if err != nil {
-		return err
+		l.compressFailed(fmt.Errorf("compress %s: %w", inputPath, err))
+	} else {
+		l.compressSucceeded()
 	}
+}
 
-	uncompressedLogFilePattern := regexp.MustCompile(`^(\d+)\.log$`)
-	for _, file := range files {
-		if matches := uncompressedLogFilePattern.FindStringSubmatch(file.Name()); matches != nil {
-			inputPath := filepath.Join(previousLogDir, file.Name())
-			outputPath := inputPath + ".gz"
-
-			err = compressFile(inputPath, outputPath)
-			if err != nil {
-				return err
-			}
+// compressFailed records a compression that failed after all retries. Once
+// CompressFailureThreshold consecutive failures are reached the logger is
+// marked unhealthy and a WARNING is written to its own output.
+func (l *Logger) compressFailed(err error) {
+	l.compressFailures.Add(1)
+	l.consecutiveCompressFailures++
 
-			err = os.Remove(inputPath)
-			if err != nil {
-				return err
-			}
-		}
+	threshold := l.config.CompressFailureThreshold
+	if threshold <= 0 {
+		threshold = defaultCompressFailureThreshold
 	}
+	if l.consecutiveCompressFailures == threshold {
+		l.setHealth("compress", err)
+		l.writeNotice(fmt.Sprintf("logger: %d consecutive compression failures, uncompressed files are accumulating: %v", threshold, err))
+	}
+}
 
-	return nil
+func (l *Logger) compressSucceeded() {
+	if l.consecutiveCompressFailures > 0 {
+		l.consecutiveCompressFailures = 0
+		l.setHealth("compress", nil)
+	}
 }
 
 func compressUncompressedFilesOnStartup(l *Logger) error {
 	logCategoryDir := filepath.Join(l.path, l.category)
 	dirs, err := os.ReadDir(logCategoryDir)
 	if err != nil {
-		log.Printf("logger: failed to read log category directory: %v\n", err)
-		return err
+		return fmt.Errorf("failed to read log category directory: %w", err)
 	}
 
-	currentDir := time.Now().Format(getDateFormat(l))
+	currentDir := l.periodDir(time.Now())
 	var dirNames []string
 	for _, dir := range dirs {
 		if dir.IsDir() && dir.Name() != currentDir {
@@ -467,43 +1428,61 @@ func compressUncompressedFilesOnStartup(l *Logger) error {
 	sort.Strings(dirNames)
 	lastFolder := dirNames[len(dirNames)-1]
 
-	err = compressPreviousUncompressedFiles(filepath.Join(logCategoryDir, lastFolder))
+	err = l.compressPreviousUncompressedFiles(filepath.Join(logCategoryDir, lastFolder))
 
 	return err
 }
 
-func compressFile(inputPath, outputPath string) error {
+// compressFile gzips inputPath into outputPath at the given gzip level.
+func compressFile(inputPath, outputPath string, level int) error {
+	return archiveFile(inputPath, outputPath, func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+}
+
+// archiveFile copies inputPath into outputPath through the compressing
+// writer newWriter returns. Errors from closing the files are returned when
+// nothing failed before them.
+func archiveFile(inputPath, outputPath string, newWriter func(io.Writer) (io.WriteCloser, error)) (err error) {
 	input, err := os.Open(inputPath)
 	if err != nil {
 		return err
 	}
 	defer func(input *os.File) {
-		err := input.Close()
-		if err != nil {
-			log.Printf("logger: %v\n", err)
+		closeErr := input.Close()
+		if err == nil {
+			err = closeErr
 		}
 	}(input)
 
-	output, err := os.Create(outputPath)
+	// The archive keeps the permissions the log file was created with
+	inputInfo, err := input.Stat()
+	if err != nil {
+		return err
+	}
+	output, err := os.OpenFile(outputPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, inputInfo.Mode().Perm())
 	if err != nil {
 		return err
 	}
 	defer func(output *os.File) {
-		err := output.Close()
-		if err != nil {
-			log.Printf("logger: %v\n", err)
+		closeErr := output.Close()
+		if err == nil {
+			err = closeErr
 		}
 	}(output)
 
-	gw := gzip.NewWriter(output)
-	defer func(gw *gzip.Writer) {
-		err := gw.Close()
-		if err != nil {
-			log.Printf("logger: %v\n", err)
+	cw, err := newWriter(output)
+	if err != nil {
+		return err
+	}
+	defer func(cw io.WriteCloser) {
+		closeErr := cw.Close()
+		if err == nil {
+			err = closeErr
 		}
-	}(gw)
+	}(cw)
 
-	_, err = io.Copy(gw, input)
+	_, err = io.Copy(cw, input)
 	if err != nil {
 		return err
 	}
@@ -511,116 +1490,963 @@ func compressFile(inputPath, outputPath string) error {
 	return nil
 }
 
-func getBytesFromSizeString(size string) int64 {
-	size = strings.TrimSpace(size)
-	unit := strings.ToUpper(size[len(size)-2:])
+// parseMaxSize parses MaxSize, where "" and "0" mean no limit and return 0.
+func parseMaxSize(size string) (int64, error) {
+	switch strings.TrimSpace(size) {
+	case "", "0":
+		return 0, nil
+	}
+	return getBytesFromSizeString(size)
+}
+
+// parseFileMode parses an octal permission string such as "0640", returning
+// fallback when mode is empty.
+func parseFileMode(mode string, fallback os.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return fallback, nil
+	}
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0777 {
+		return 0, fmt.Errorf("invalid file mode: %s", mode)
+	}
+	return os.FileMode(perm), nil
+}
 
-	value, err := strconv.ParseFloat(size[:len(size)-2], 64)
+// getBytesFromSizeString parses sizes such as "16kb", "8 MB", "512b" or a
+// bare number of bytes. Units (B, KB, MB, GB, TB) are case-insensitive and
+// 1KB is 1024 bytes. Sizes below one byte are rejected.
+func getBytesFromSizeString(size string) (int64, error) {
+	trimmed := strings.TrimSpace(size)
+	number, unit := trimmed, ""
+	if i := strings.IndexFunc(trimmed, unicode.IsLetter); i >= 0 {
+		number, unit = strings.TrimSpace(trimmed[:i]), strings.ToUpper(trimmed[i:])
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
 	if err != nil {
-		log.Printf("logger: invalid size string: %s\n", size)
-		return 8 * 1024 * 1024
+		return 0, fmt.Errorf("invalid size string: %s", size)
 	}
 
-	var bytes int64
+	var multiplier float64
 	switch unit {
+	case "", "B":
+		multiplier = 1
 	case "KB":
-		bytes = int64(value * 1024)
+		multiplier = 1024
 	case "MB":
-		bytes = int64(value * 1024 * 1024)
+		multiplier = 1024 * 1024
 	case "GB":
-		bytes = int64(value * 1024 * 1024 * 1024)
+		multiplier = 1024 * 1024 * 1024
+	case "TB":
+		multiplier = 1024 * 1024 * 1024 * 1024
 	default:
-		log.Printf("logger: invalid size string: %s\n", size)
-		return 8 * 1024 * 1024
+		return 0, fmt.Errorf("invalid size string: %s", size)
 	}
-	return bytes
-}
 
-func setLogColor(level LogLevel) {
-	switch level {
-	case ERROR:
-		color.Set(color.FgRed)
-	case FATAL:
-		color.Set(color.FgRed)
-	case WARNING:
-		color.Set(color.FgYellow)
-	case JEDI:
-		color.Set(color.FgGreen)
-	case INFO:
-		color.Set(color.Reset)
-	case DEBUG:
-		color.Set(color.FgBlue)
-	default:
-		color.Set(color.Reset)
+	bytes := value * multiplier
+	if bytes < 1 || bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size out of range: %s", size)
 	}
+	return int64(bytes), nil
 }
 
-func (l *Logger) logf(level LogLevel, format string, v ...interface{}) {
-	if level < l.level {
+func (l *Logger) logf(level LogLevel, fields map[string]interface{}, format string, v ...interface{}) {
+	if l.disabled.Load() {
 		return
 	}
 
-	now := time.Now()
-	timeFormatted := now.Format("2006-01-02T15:04:05.000Z07:00")
+	// A sampled fraction of DEBUG lines bypasses the level gate
+	if !l.passesLevel(level) && !(level == DEBUG && l.sampleDebug()) {
+		return
+	}
+	if !l.sample(level) {
+		return
+	}
 
-	message := fmt.Sprintf(format, v...)
-	logLine := fmt.Sprintf("%s %-9s %s\n", timeFormatted, fmt.Sprintf("[%s]", level.toString()), message)
+	message := sanitizeUTF8(fmt.Sprintf(format, v...), l.config.InvalidUTF8)
 
 	logContent := LogContent{
 		Level:     level,
 		Timestamp: time.Now(),
-		Message:   logLine,
+		Message:   message,
+		Fields:    l.withStack(level, fields, v),
+	}
+	if l.config.AddCaller {
+		logContent.Caller = caller()
+	}
+	if l.stackTraces && level >= l.stackTraceLevel {
+		logContent.StackTrace = stackTrace()
+	}
+	// Masked once everything is attached, so stacks are covered too
+	if l.redactions != nil {
+		logContent.Message = l.redact(logContent.Message)
+		logContent.Fields = l.redactFields(logContent.Fields)
+		logContent.StackTrace = l.redact(logContent.StackTrace)
+	}
+	logContent.Message = truncateMessage(logContent.Message, l.config.MaxMessageLength)
+	if l.dedup != nil && !l.dedupAllow(logContent) {
+		return
 	}
 
-	l.logQueue <- logContent
+	l.enqueue(logContent)
 }
 
-func (l *Logger) startLogging() {
-	for logLine := range l.logQueue {
-		if l.file != nil {
-			fileInfo, err := os.Stat(l.file.Name())
+// batch queues events as one grouped entry. The block is written with a
+// single write, so it always lands whole in one file: size rotation is
+// checked before it and RotateEveryNLines counts it as one line.
+func (l *Logger) batch(level LogLevel, fields map[string]interface{}, events []string) {
+	if len(events) == 0 || l.disabled.Load() || !l.passesLevel(level) {
+		return
+	}
+
+	logContent := LogContent{
+		Level:     level,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("batch of %d events", len(events)),
+		Fields:    l.redactFields(fields),
+		Events:    make([]string, len(events)),
+	}
+	for i, event := range events {
+		logContent.Events[i] = l.redact(sanitizeUTF8(event, l.config.InvalidUTF8))
+	}
+	if l.config.AddCaller {
+		logContent.Caller = caller()
+	}
+
+	l.enqueue(logContent)
+}
+
+// severity returns the rank used to compare level against the threshold.
+// With a grouped LevelFilter JEDI ranks alongside INFO.
+func (l *Logger) severity(level LogLevel) LogLevel {
+	if l.groupJedi && level == JEDI {
+		return INFO
+	}
+	return level
+}
+
+// passesLevel reports whether an entry at level clears the logger's level.
+func (l *Logger) passesLevel(level LogLevel) bool {
+	return l.severity(level) >= l.severity(l.GetLevel())
+}
+
+// SetLevel changes the minimum level written. It is safe to call while other
+// goroutines are logging.
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level.Store(int32(level))
+}
+
+// GetLevel returns the minimum level written.
+func (l *Logger) GetLevel() LogLevel {
+	return LogLevel(l.level.Load())
+}
+
+// ParseLevel returns the level named s, as accepted by Config.Level ("debug",
+// "info", "jedi", "warning", "error" or "fatal"), ignoring case, and whether
+// s names a level. It is the inverse of LogLevel.String.
+func ParseLevel(s string) (LogLevel, bool) {
+	level, ok := levelMapping[strings.ToLower(strings.TrimSpace(s))]
+	return level, ok
+}
+
+// formatLine renders entry as text, including the trailing newline. Batched
+// events follow on their own lines, indented to the message column.
+func (l *Logger) formatLine(entry LogContent) string {
+	if l.config.Format == "json" {
+		return l.formatJSON(entry)
+	}
+	if l.config.Format == "logfmt" {
+		return l.formatLogfmt(entry)
+	}
+
+	var line string
+	var prefixWidth int
+	if l.template != nil && l.config.Format != "lnav" {
+		line, prefixWidth = l.renderTemplate(entry)
+		line += "\n"
+	} else {
+		timeFormatted := l.formatTime(entry.Timestamp)
+
+		var prefix string
+		if l.config.Format == "lnav" {
+			prefix = fmt.Sprintf("%s %s ", timeFormatted, lnavLevel(entry.Level))
+		} else {
+			prefix = fmt.Sprintf("%s %-9s ", timeFormatted, fmt.Sprintf("[%s]", entry.Level.String()))
+		}
+		line = prefix + l.message(entry) + formatCaller(entry.Caller) + formatFields(entry.Fields) + "\n"
+		prefixWidth = len(prefix)
+	}
+	if len(entry.Events) == 0 && entry.StackTrace == "" {
+		return line
+	}
+
+	indent := strings.Repeat(" ", prefixWidth)
+	var sb strings.Builder
+	sb.WriteString(line)
+	for _, event := range entry.Events {
+		sb.WriteString(indent)
+		sb.WriteString("- ")
+		sb.WriteString(event)
+		sb.WriteString("\n")
+	}
+	writeStackTrace(&sb, indent, entry.StackTrace)
+	return sb.String()
+}
+
+// writeNotice writes a notice about the logger itself straight to its
+// outputs at NoticeLevel, bypassing the queue and, unless NoticeLevel is set,
+// the level filter. It must be called from the writing goroutine with l.mu
+// held.
+func (l *Logger) writeNotice(message string) {
+	if l.noticeGated && !l.passesLevel(l.noticeLevel) {
+		return
+	}
+	l.write(LogContent{Level: l.noticeLevel, Timestamp: time.Now(), Message: message, Fields: l.fields})
+}
+
+// write renders entry and writes it to the console, if enabled, and to the
+// file output.
+func (l *Logger) write(entry LogContent) {
+	line := l.formatLine(entry)
+
+	timedOut := l.writeConsole(entry, line)
+	if entry.Level >= ERROR {
+		l.openErrorLog()
+	}
+	plain := stripANSI(line)
+	timedOut = l.writeFile(plain) || timedOut
+	l.writeErrorLog(entry.Level, plain)
+	l.countLine(entry.Level)
+
+	if timedOut {
+		l.drop(1)
+	}
+	l.recordWriteTimeout(timedOut)
+}
+
+// writeBatch writes entries taken from the queue in one go. Console lines are
+// written one at a time, while file lines are gathered and written with a
+// single write, split only where the file has to be reopened or rotated.
+// Entries are written in queue order.
+func (l *Logger) writeBatch(batch []LogContent) {
+	buf := l.batchBuf[:0]
+	buffered := 0
+	timedOut := false
+	hooks := l.loadHooks()
+
+	flush := func() {
+		if buffered == 0 {
+			return
+		}
+		if l.writeFile(string(buf)) {
+			l.drop(uint64(buffered))
+			timedOut = true
+		}
+		buf = buf[:0]
+		buffered = 0
+	}
+
+	for _, entry := range batch {
+		if entry.control != controlNone {
+			flush()
+			// A waiting caller is answered even while draining so it returns
+			if !l.draining.Load() || entry.done != nil {
+				l.handleControl(entry)
+			}
+			continue
+		}
+
+		if l.draining.Load() {
+			l.pending = append(l.pending, entry)
+			continue
+		}
+
+		if !l.customOutput && l.config.ReopenCheckInterval > 0 && time.Since(l.lastReopenTime) >= l.config.ReopenCheckInterval {
+			flush()
+			l.lastReopenTime = time.Now()
+			l.reopenIfRemoved()
+		}
+
+		if l.customOutput {
+			// Outputs set with SetOutput are never rotated
+		} else if l.periodClosed || !time.Now().Before(l.nextRotateTime) {
+			// A new period starts a new directory, whatever the file size
+			flush()
+			l.compressMu.Lock()
+			err := l.rotate()
 			if err != nil {
-				log.Printf("logger (file stat): %v\n", err)
-			} else {
-				if fileInfo.Size() >= l.maxSize {
-					l.compressMu.Lock()
-					l.rotate()
-					l.compress()
-					l.compressMu.Unlock()
-				}
+				l.reportError(err)
 			}
+			l.removeOldBackups()
+			l.compressMu.Unlock()
+		} else if l.shouldRotate(int64(len(buf))) {
+			flush()
+			l.compressMu.Lock()
+			err := l.rotate()
+			if err != nil {
+				l.reportError(err)
+			}
+			l.removeOldBackups()
+			l.compressMu.Unlock()
+		}
+
+		line := l.formatLine(entry)
+		if l.writeConsole(entry, line) {
+			l.drop(1)
+			timedOut = true
+		}
+		plain := stripANSI(line)
+		if entry.Level >= ERROR && l.config.SeparateErrorLog && l.errorWriter == nil {
+			l.mu.Lock()
+			l.openErrorLog()
+			l.mu.Unlock()
 		}
+		l.writeErrorLog(entry.Level, plain)
+		buf = append(buf, plain...)
+		buffered++
+		l.linesWritten++
+		l.countLine(entry.Level)
+		if len(hooks) > 0 {
+			l.hooked = append(l.hooked, entry)
+		}
+	}
+	flush()
+
+	l.batchBuf = buf
+	l.recordWriteTimeout(timedOut)
+
+	// Hooks see entries once they are written
+	skipped := 0
+	for i, entry := range l.hooked {
+		if !l.queueHooks(hooks, entry) {
+			skipped++
+		}
+		l.hooked[i] = LogContent{}
+	}
+	l.hooked = l.hooked[:0]
+	if skipped > 0 {
+		l.reportError(fmt.Errorf("hooks are falling behind: skipped them for %d entries", skipped))
+	}
+}
+
+// writeConsole writes entry to the console, if enabled, and reports whether
+// the write timed out.
+func (l *Logger) writeConsole(entry LogContent, line string) bool {
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+
+	if l.console == nil {
+		return false
+	}
+
+	var err error
+	if l.config.Format == "pretty" {
+		err = l.writeTo(l.console, l.formatPretty(entry, l.consoleWidth))
+	} else if l.colorize {
+		// Colour the line itself rather than the terminal's global state,
+		// which other loggers share
+		err = l.writeTo(l.console, l.paintLevel(entry.Level, strings.TrimSuffix(line, "\n"))+"\n")
+	} else {
+		err = l.writeTo(l.console, line)
+	}
+	if err != nil {
+		l.reportError(fmt.Errorf("console write: %w", err))
+		l.emit(Event{Type: WriteError, Err: err})
+		return errors.Is(err, ErrWriteTimeout)
+	}
+	return false
+}
+
+// writeFile writes rendered lines to the file output and any extra writers,
+// and reports whether the file write timed out.
+func (l *Logger) writeFile(lines string) bool {
+	l.outMu.Lock()
+	path := ""
+	if fw, ok := l.out.(*FileWriter); ok {
+		path = fw.file.Name()
+	}
+	err := l.writeTo(l.out, lines)
+	if err != nil && !errors.Is(err, ErrWriteTimeout) && l.config.OnWriteError == "retry" {
+		err = l.retryWrite(lines)
+	}
+	for _, w := range l.writers {
+		// A failing extra sink doesn't affect the file or the other sinks
+		if sinkErr := l.writeTo(w, lines); sinkErr != nil {
+			l.reportError(fmt.Errorf("extra writer: %w", sinkErr))
+		}
+	}
+	l.outMu.Unlock()
+	if err == nil {
+		l.bytesWritten.Add(uint64(len(lines)))
+		l.recordWriteError(nil)
+		return false
+	}
+
+	l.reportError(fmt.Errorf("write: %w", err))
+	l.emit(Event{Type: WriteError, Path: path, Err: err})
+	if errors.Is(err, ErrWriteTimeout) {
+		return true
+	}
+	l.recordWriteError(err)
+	if l.config.OnWriteError == "stderr" {
+		_, _ = io.WriteString(os.Stderr, lines)
+	}
+	return false
+}
+
+// retryWrite writes lines to the file output again after a failed write,
+// backing off between attempts, and returns the last error. It must be
+// called with l.outMu held.
+func (l *Logger) retryWrite(lines string) error {
+	backoff := writeRetryBackoff
+	var err error
+	for attempt := 1; attempt <= writeRetryAttempts; attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = l.writeTo(l.out, lines)
+		if err == nil || errors.Is(err, ErrWriteTimeout) {
+			break
+		}
+	}
+	return err
+}
 
-		setLogColor(logLine.Level)
-		_, err := l.out.Write([]byte(logLine.Message))
+// recordWriteError tracks failing file writes: the logger is marked
+// unhealthy by the first failure and healthy again by the next successful
+// write.
+func (l *Logger) recordWriteError(err error) {
+	if err != nil {
+		if l.writeFailures.Add(1) == 1 {
+			l.setHealth("file", err)
+		}
+	} else if l.writeFailures.Swap(0) > 0 {
+		l.setHealth("file", nil)
+	}
+}
+
+// countLine records a line written at level for Stats.
+func (l *Logger) countLine(level LogLevel) {
+	if level >= DEBUG && level <= FATAL {
+		l.levelCounts[level].Add(1)
+	}
+}
+
+// recordWriteTimeout marks writes unhealthy after a timeout and healthy again
+// once writes complete in time.
+func (l *Logger) recordWriteTimeout(timedOut bool) {
+	if timedOut {
+		l.setHealth("write", ErrWriteTimeout)
+	} else if l.config.WriteTimeout > 0 {
+		l.setHealth("write", nil)
+	}
+}
+
+// writeTo writes s to w, giving up after WriteTimeout when one is set.
+func (l *Logger) writeTo(w io.Writer, s string) error {
+	timeout := l.config.WriteTimeout
+	if timeout <= 0 {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+
+	if d, ok := w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		if d.SetWriteDeadline(time.Now().Add(timeout)) == nil {
+			_, err := io.WriteString(w, s)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return ErrWriteTimeout
+			}
+			return err
+		}
+	}
+
+	// Don't pile up goroutines behind a sink that is still blocked
+	if _, stalled := l.stalledWrites.Load(w); stalled {
+		return ErrWriteTimeout
+	}
+
+	result := make(chan error, 1)
+	l.stalledWrites.Store(w, true)
+	go func() {
+		_, err := io.WriteString(w, s)
+		l.stalledWrites.Delete(w)
+		result <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return ErrWriteTimeout
+	}
+}
+
+// lnavLevel returns the level name used by the lnav format. lnav has no JEDI
+// level, so it is written as NOTICE, which lnav places between INFO and
+// WARNING as well.
+func lnavLevel(level LogLevel) string {
+	if level == JEDI {
+		return "NOTICE"
+	}
+	return level.String()
+}
+
+// writeStackTrace writes each line of stack below an entry, indented by
+// indent.
+func writeStackTrace(sb *strings.Builder, indent, stack string) {
+	if stack == "" {
+		return
+	}
+	for _, line := range strings.Split(stack, "\n") {
+		sb.WriteString(indent)
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+}
+
+// formatCaller renders the call site that follows the message in text
+// formats, or "" when none was recorded.
+func formatCaller(caller string) string {
+	if caller == "" {
+		return ""
+	}
+	return " " + caller
+}
+
+// formatFields renders fields as " key=value" pairs in key order, ready to be
+// appended to a text line.
+func formatFields(fields map[string]interface{}) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		value := fmt.Sprint(fields[key])
+		// Keep multi-line values such as stacks on the entry's line
+		if strings.ContainsAny(value, "\r\n") {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(fmt.Sprintf(" %s=%s", key, value))
+	}
+	return sb.String()
+}
+
+// enqueue hands an entry to the writing goroutine and reports whether it was
+// queued. Entries logged after the queue has been closed are discarded.
+// When the queue is full the OverflowPolicy decides between waiting,
+// dropping the entry and dropping the oldest queued entry; instructions to
+// the writer always wait.
+func (l *Logger) enqueue(logContent LogContent) bool {
+	policy := l.config.OverflowPolicy
+	wait := logContent.control != controlNone || (policy != "drop" && policy != "dropOldest")
+	queued, dropped := l.queue.push(logContent, wait, policy == "dropOldest")
+	if dropped {
+		l.drop(1)
+		l.overflowed.Add(1)
+	}
+	return queued
+}
+
+// Dropped returns how many entries were not written: discarded by the
+// OverflowPolicy because the queue was full, or abandoned after a write
+// exceeded WriteTimeout.
+func (l *Logger) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// reportOverflow writes a notice with the number of entries the
+// OverflowPolicy dropped since the last one. It is called by the writer.
+func (l *Logger) reportOverflow() {
+	n := l.overflowed.Swap(0)
+	if n == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	l.writeNotice(fmt.Sprintf("dropped %d entries because the log queue was full", n))
+	l.mu.Unlock()
+}
+
+// closeQueue stops accepting entries and waits for the writing goroutine to
+// finish with the ones already queued. It reports false if the queue was
+// already closed.
+func (l *Logger) closeQueue() bool {
+	if !l.queue.close() {
+		return false
+	}
+
+	<-l.done
+	close(l.hookQueue)
+	<-l.hooksDone
+	close(l.quit)
+	// Let the compressor finish the files handed to it
+	<-l.compressorDone
+	return true
+}
+
+// closeFiles releases the active log file and the category lock.
+func (l *Logger) closeFiles() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var err error
+	if l.fileWriter != nil {
+		err = l.fileWriter.Close()
+		l.fileWriter = nil
+		l.file = nil
+	}
+	l.closeErrorLog()
+	if l.lockFile != nil {
+		_ = l.lockFile.Close()
+		l.lockFile = nil
+	}
+	l.leaveCategoryDir()
+	return err
+}
+
+// Close writes every queued entry, then the summary line when SummaryLevel is
+// set, and closes the log files, returning the error from flushing or closing
+// the active file. Lines still queued when a program exits are lost, so defer
+// Close in main. Logging after Close is ignored and calling it again returns
+// nil.
+func (l *Logger) Close() error {
+	return l.close(false)
+}
+
+// close implements Close, syncing the active file to disk before closing it
+// when sync is set.
+func (l *Logger) close(sync bool) error {
+	if l.dedup != nil {
+		// Report duplicates of windows still open
+		l.flushDedup(true)
+	}
+	if l.summary {
+		// Written by the writer after everything queued before it
+		l.enqueue(LogContent{control: controlSummary})
+	}
+	if !l.closeQueue() {
+		return nil
+	}
+
+	if sync {
+		l.mu.Lock()
+		for _, fw := range []*FileWriter{l.fileWriter, l.errorWriter} {
+			if fw == nil {
+				continue
+			}
+			err := fw.Sync()
+			if err != nil {
+				l.reportError(fmt.Errorf("sync: %w", err))
+			}
+		}
+		l.mu.Unlock()
+	}
+
+	return l.closeFiles()
+}
+
+// exit writes the queued entries, the fatal one included, syncs them to disk
+// and exits the process with FatalExitCode. With FatalNoExit it returns once
+// they are synced instead, leaving the logger open.
+func (l *Logger) exit() {
+	if l.config.FatalNoExit {
+		err := l.Flush()
 		if err != nil {
-			log.Printf("logger (write): %v\n", err)
+			l.reportError(err)
+		}
+		return
+	}
+
+	err := l.close(true)
+	if err != nil {
+		l.reportError(err)
+	}
+	code := l.config.FatalExitCode
+	if code == 0 {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// writeSummary writes the line summarizing the run. The summary describes
+// what was written before it, so it isn't counted itself. It must be called
+// from the writing goroutine.
+func (l *Logger) writeSummary() {
+	stats := l.Stats()
+
+	fields := make(map[string]interface{}, len(l.fields)+len(stats.Levels)+4)
+	for key, value := range l.fields {
+		fields[key] = value
+	}
+	fields["duration"] = time.Since(l.started).Round(time.Millisecond)
+	for level, count := range stats.Levels {
+		fields[strings.ToLower(level.String())+"_lines"] = count
+	}
+	fields["bytes"] = stats.Bytes
+	fields["dropped"] = stats.Dropped
+	fields["compress_failures"] = stats.CompressFailures
+	fields["rotations"] = stats.Rotations
+	fields["sampled"] = stats.Sampled
+
+	entry := LogContent{Level: l.summaryLevel, Timestamp: time.Now(), Message: "summary", Fields: fields}
+	line := l.formatLine(entry)
+	l.writeConsole(entry, line)
+	l.writeFile(line)
+}
+
+// Flush returns once every entry logged before the call has been written and
+// the active file has been synced to disk, returning any error from the
+// sync. The logger stays open. After Close it returns nil straight away.
+func (l *Logger) Flush() error {
+	done := make(chan error, 1)
+	if !l.enqueue(LogContent{control: controlFlush, done: done}) {
+		return nil
+	}
+	return <-done
+}
+
+// DrainPending stops the logger and returns the entries that were still
+// queued, without writing them, so the caller can persist them another way
+// (e.g. in a crash dump). Entries already being written when it is called are
+// written as usual and not returned. The logger's files are closed afterwards
+// and later logging calls are ignored. Calling it again returns nil.
+func (l *Logger) DrainPending() []LogContent {
+	l.draining.Store(true)
+	if !l.closeQueue() {
+		return nil
+	}
+
+	err := l.closeFiles()
+	if err != nil {
+		l.reportError(err)
+	}
+
+	pending := l.pending
+	l.pending = nil
+	return pending
+}
+
+// startLogging runs the writer. It takes entries off the queue in batches of
+// whatever has accumulated, up to writeBatchSize, so producers rarely have to
+// wake it and the file sees one write per batch rather than one per line.
+func (l *Logger) startLogging() {
+	defer close(l.done)
+
+	batch := make([]LogContent, 0, writeBatchSize)
+	for {
+		var ok bool
+		batch, ok = l.queue.take(batch, writeBatchSize)
+		if !ok {
+			return
 		}
-		color.Unset()
+
+		l.writeBatch(batch)
+		l.reportOverflow()
+	}
+}
+
+// reopenIfRemoved reopens the active log file by name when the name no
+// longer refers to the file we hold open.
+func (l *Logger) reopenIfRemoved() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fileWriter == nil {
+		return
+	}
+
+	filename := l.fileWriter.file.Name()
+	nameInfo, err := os.Stat(filename)
+	if err == nil {
+		openInfo, err := l.fileWriter.Stat()
+		if err != nil || os.SameFile(nameInfo, openInfo) {
+			return
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		l.reportError(err)
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), l.dirMode)
+	if err != nil {
+		l.reportError(err)
+		return
 	}
+	fileWriter, err := l.openFileWriter(filename)
+	if err != nil {
+		l.reportError(err)
+		return
+	}
+
+	_ = l.fileWriter.Close()
+	l.fileWriter = fileWriter
+	l.file = fileWriter.file
+	l.linesWritten = 0
+	l.out = fileWriter
+}
+
+// shouldRotate reports whether the current file is full, either by line
+// count when RotateEveryNLines is set or by size, counting buffered bytes not
+// yet written. A maxSize of 0 never fills by size.
+func (l *Logger) shouldRotate(buffered int64) bool {
+	if l.fileWriter == nil {
+		return false
+	}
+
+	if l.config.RotateEveryNLines > 0 && l.linesWritten >= l.config.RotateEveryNLines {
+		return true
+	}
+
+	return l.maxSize > 0 && l.fileWriter.Written()+buffered >= l.maxSize
+}
+
+// Stats returns a snapshot of the logger's counters.
+func (l *Logger) Stats() Stats {
+	return l.stats(func(counter *atomic.Uint64) uint64 { return counter.Load() })
+}
+
+// ResetStats zeroes the logger's counters and returns the values they held.
+// Each counter is read and zeroed in one atomic step, so every increment is
+// counted either in the returned snapshot or after the reset, never lost; a
+// poll-and-reset scraper should use the returned Stats rather than calling
+// Stats first.
+func (l *Logger) ResetStats() Stats {
+	return l.stats(func(counter *atomic.Uint64) uint64 { return counter.Swap(0) })
+}
+
+// stats builds a Stats from the counters, reading each with read.
+func (l *Logger) stats(read func(*atomic.Uint64) uint64) Stats {
+	levels := make(map[LogLevel]uint64, len(l.levelCounts))
+	for level := range l.levelCounts {
+		levels[LogLevel(level)] = read(&l.levelCounts[level])
+	}
+
+	return Stats{
+		CompressFailures: read(&l.compressFailures),
+		Dropped:          read(&l.dropped),
+		Levels:           levels,
+		Bytes:            read(&l.bytesWritten),
+		Rotations:        read(&l.rotations),
+		Sampled:          read(&l.sampled),
+	}
+}
+
+// Healthy reports whether the logger is operating normally. It returns false
+// while compression keeps failing past CompressFailureThreshold, writes keep
+// exceeding WriteTimeout or writes to the log file fail.
+func (l *Logger) Healthy() bool {
+	l.healthMu.Lock()
+	defer l.healthMu.Unlock()
+	return len(l.healthErrs) == 0
+}
+
+// setHealth records err as the reason source (e.g. "compress" or "write") is
+// unhealthy, or clears it when err is nil. The logger is healthy when no
+// source has an error recorded.
+func (l *Logger) setHealth(source string, err error) {
+	l.healthMu.Lock()
+	wasHealthy := len(l.healthErrs) == 0
+	if err == nil {
+		delete(l.healthErrs, source)
+	} else {
+		if l.healthErrs == nil {
+			l.healthErrs = make(map[string]error)
+		}
+		l.healthErrs[source] = err
+	}
+	changed := wasHealthy != (len(l.healthErrs) == 0)
+	l.healthMu.Unlock()
+
+	if changed {
+		l.signalHealthChange()
+	}
+}
+
+// SetDebugSample changes the fraction of DEBUG lines let through while the
+// level is above DEBUG. Values are clamped to the range 0 to 1.
+func (l *Logger) SetDebugSample(rate float64) {
+	if rate < 0 || math.IsNaN(rate) {
+		rate = 0
+	} else if rate > 1 {
+		rate = 1
+	}
+	l.debugSample.Store(math.Float64bits(rate))
+}
+
+// DebugSample returns the fraction of DEBUG lines let through while the level
+// is above DEBUG.
+func (l *Logger) DebugSample() float64 {
+	return math.Float64frombits(l.debugSample.Load())
+}
+
+// sampleDebug decides whether a DEBUG line below the level should be kept.
+func (l *Logger) sampleDebug() bool {
+	rate := l.DebugSample()
+	return rate > 0 && rand.Float64() < rate
+}
+
+// SetEnabled turns the logger on or off at runtime. While disabled every
+// logging call returns immediately, before any formatting or queueing, and
+// the entries are discarded rather than buffered.
+func (l *Logger) SetEnabled(enabled bool) {
+	l.disabled.Store(!enabled)
+}
+
+// Enabled reports whether the logger is currently enabled.
+func (l *Logger) Enabled() bool {
+	return !l.disabled.Load()
+}
+
+// Batch logs related events as a single entry sharing one timestamp: a
+// header line followed by one indented line per event.
+func (l *Logger) Batch(level LogLevel, events []string) {
+	l.batch(level, l.fields, events)
+}
+
+// Once logs at level only the first time key is seen by this logger, e.g.
+// for deprecation notices that would otherwise repeat on every call. The key
+// is used up even if the line is filtered out by the level.
+func (l *Logger) Once(level LogLevel, key string, format string, v ...interface{}) {
+	if _, seen := l.onceKeys.LoadOrStore(key, struct{}{}); seen {
+		return
+	}
+	l.logf(level, l.fields, format, v...)
+}
+
+// WarnOnce logs a WARNING only the first time key is seen by this logger.
+func (l *Logger) WarnOnce(key string, format string, v ...interface{}) {
+	l.Once(WARNING, key, format, v...)
 }
 
 func (l *Logger) Debugf(format string, v ...interface{}) {
-	l.logf(DEBUG, format, v...)
+	l.logf(DEBUG, l.fields, format, v...)
 }
 
 func (l *Logger) Infof(format string, v ...interface{}) {
-	l.logf(INFO, format, v...)
+	l.logf(INFO, l.fields, format, v...)
 }
 
 func (l *Logger) Jedif(format string, v ...interface{}) {
-	l.logf(JEDI, format, v...)
+	l.logf(JEDI, l.fields, format, v...)
 }
 
 func (l *Logger) Warningf(format string, v ...interface{}) {
-	l.logf(WARNING, format, v...)
+	l.logf(WARNING, l.fields, format, v...)
 }
 
 func (l *Logger) Errorf(format string, v ...interface{}) {
-	l.logf(ERROR, format, v...)
+	l.logf(ERROR, l.fields, format, v...)
 }
 
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.logf(FATAL, format, v...)
-	os.Exit(1)
+	l.logf(FATAL, l.fields, format, v...)
+	l.exit()
 }
@@ -0,0 +1,1318 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imkiptoo/logger/encrypt"
+)
+
+// newTestLogger returns a logger writing to a temporary directory, closed
+// when the test ends.
+func newTestLogger(t testing.TB, config Config) *Logger {
+	t.Helper()
+	l, err := New("test", t.TempDir(), "app", config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+// readLines returns the lines of every log file of l, oldest first.
+func readLines(t testing.TB, l *Logger) []string {
+	t.Helper()
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+
+	var lines []string
+	for _, path := range files {
+		lines = append(lines, readFileLines(t, path)...)
+	}
+	return lines
+}
+
+// readFileLines returns the lines of one log file or archive.
+func readFileLines(t testing.TB, path string) []string {
+	t.Helper()
+	r, err := OpenLogReader(path)
+	if err != nil {
+		t.Fatalf("OpenLogReader(%s): %v", path, err)
+	}
+	defer r.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return lines
+}
+
+// syncBuffer is a bytes.Buffer safe to read while the writer uses it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// blockingWriter stalls every write until release is closed, signalling
+// entered on the first one, and keeps what was written.
+type blockingWriter struct {
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+	written syncBuffer
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.entered) })
+	<-w.release
+	return w.written.Write(p)
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDropOldestKeepsControlEntries(t *testing.T) {
+	l := newTestLogger(t, Config{QueueSize: 1, OverflowPolicy: "dropOldest"})
+	w := newBlockingWriter()
+	l.SetOutput(w)
+
+	// Stall the writer on the first entry, then fill the queue with a Flush
+	l.Infof("first")
+	<-w.entered
+	flushed := make(chan error)
+	go func() { flushed <- l.Flush() }()
+	waitFor(t, "the flush to be queued", func() bool { return l.queue.len() == 1 })
+
+	l.Infof("second")
+	close(w.release)
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush never returned: its entry was evicted")
+	}
+	if got := l.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+}
+
+func TestSummaryWrittenAfterIdlePeriod(t *testing.T) {
+	l := newTestLogger(t, Config{Frequency: "secondly", SummaryLevel: "info"})
+	l.Infof("before the boundary")
+
+	// Let the period end with nothing logged, so the writer closes its file
+	waitForEvent(t, l, Rotated)
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 2 || !strings.Contains(lines[1], "summary") {
+		t.Fatalf("lines = %q, want the entry and the summary", lines)
+	}
+}
+
+// BenchmarkLogfParallel measures the producer side of the queue: the time
+// logging calls from many goroutines spend handing their entries to the
+// writer, reported with its 99th percentile.
+func BenchmarkLogfParallel(b *testing.B) {
+	for _, policy := range []string{"block", "drop"} {
+		b.Run(policy, func(b *testing.B) {
+			l := newTestLogger(b, Config{OverflowPolicy: policy})
+			l.SetOutput(io.Discard)
+
+			var mu sync.Mutex
+			var latencies []time.Duration
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				local := make([]time.Duration, 0, 1024)
+				for pb.Next() {
+					start := time.Now()
+					l.Infof("request %d handled", 42)
+					local = append(local, time.Since(start))
+				}
+				mu.Lock()
+				latencies = append(latencies, local...)
+				mu.Unlock()
+			})
+			b.StopTimer()
+
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			if len(latencies) > 0 {
+				b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+			}
+		})
+	}
+}
+
+func TestOpenLogFileSkipsClaimedIndexes(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	dir := t.TempDir()
+	// Another process has claimed index 1 and archived index 2
+	for _, name := range []string{"1.log", "2.log.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("theirs\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, index, err := l.openLogFile(dir, 1)
+	if err != nil {
+		t.Fatalf("openLogFile: %v", err)
+	}
+	defer file.Close()
+	if index != 3 {
+		t.Errorf("claimed index %d, want 3", index)
+	}
+	if lines := readFileLines(t, filepath.Join(dir, "1.log")); len(lines) != 1 || lines[0] != "theirs" {
+		t.Errorf("existing file changed: %q", lines)
+	}
+}
+
+func TestReopenAfterActiveFileRemoved(t *testing.T) {
+	l := newTestLogger(t, Config{ReopenCheckInterval: time.Nanosecond})
+	l.Infof("lost with the file")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	files, err := l.Files()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Files() = %v, %v; want the active file", files, err)
+	}
+	if err := os.Remove(files[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Infof("after the removal")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	lines := readFileLines(t, files[0])
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "after the removal") {
+		t.Errorf("recreated file = %q, want the line logged after the removal", lines)
+	}
+}
+
+func TestFixedWidthTime(t *testing.T) {
+	layout := "15:04:05.999999"
+	zero := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	nonZero := time.Date(2024, 1, 2, 3, 4, 5, 123400000, time.UTC)
+
+	loose := newTestLogger(t, Config{TimeFormat: layout})
+	if len(loose.formatTime(zero)) == len(loose.formatTime(nonZero)) {
+		t.Fatal("the layout already has a fixed width; the test proves nothing")
+	}
+
+	fixed := newTestLogger(t, Config{TimeFormat: layout, FixedWidthTime: true})
+	a, b := fixed.formatTime(zero), fixed.formatTime(nonZero)
+	if len(a) != len(b) {
+		t.Errorf("widths differ: %q and %q", a, b)
+	}
+	if a != "03:04:05.000000" || b != "03:04:05.123400" {
+		t.Errorf("got %q and %q", a, b)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	l.SetEnabled(false)
+	if l.Enabled() {
+		t.Fatal("Enabled() = true after SetEnabled(false)")
+	}
+	l.Errorf("hidden")
+	l.SetEnabled(true)
+	l.Infof("shown")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "shown") {
+		t.Errorf("lines = %q, want only the line logged while enabled", lines)
+	}
+}
+
+// BenchmarkDisabled compares a disabled logger with one filtering the line
+// by level and one writing it.
+func BenchmarkDisabled(b *testing.B) {
+	cases := []struct {
+		name    string
+		level   string
+		enabled bool
+	}{
+		{"disabled", "debug", false},
+		{"filtered", "error", true},
+		{"enabled", "debug", true},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			l := newTestLogger(b, Config{Level: c.level})
+			l.SetOutput(io.Discard)
+			l.SetEnabled(c.enabled)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Infof("request %d handled", i)
+			}
+		})
+	}
+}
+
+// failingCompressor fails its first n calls, then compresses normally.
+func failingCompressor(n int) func(string, string, int) error {
+	var calls atomic.Int32
+	return func(inputPath, outputPath string, level int) error {
+		if int(calls.Add(1)) <= n {
+			return errors.New("disk full")
+		}
+		return compressFile(inputPath, outputPath, level)
+	}
+}
+
+func TestCompressRetriesFailures(t *testing.T) {
+	l := newTestLogger(t, Config{Compress: true, CompressAttempts: 3})
+	l.compressor = failingCompressor(2)
+
+	l.Infof("rotated away")
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if event := waitForEvent(t, l, Compressed); event.Err != nil {
+		t.Fatalf("compression failed despite retries: %v", event.Err)
+	}
+	if got := l.Stats().CompressFailures; got != 0 {
+		t.Errorf("CompressFailures = %d, want 0", got)
+	}
+}
+
+func TestRepeatedCompressFailuresAreReported(t *testing.T) {
+	l := newTestLogger(t, Config{Compress: true, CompressAttempts: 1, CompressFailureThreshold: 2})
+	l.compressor = failingCompressor(1000)
+	var buf syncBuffer
+	l.AddWriter(&buf)
+
+	for i := 0; i < 2; i++ {
+		l.Infof("rotated away")
+		if err := l.Rotate(); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+		if event := waitForEvent(t, l, Compressed); event.Err == nil {
+			t.Fatal("compression succeeded with a failing compressor")
+		}
+		if i == 0 && !l.Healthy() {
+			t.Error("unhealthy after one failure, below the threshold")
+		}
+	}
+
+	if l.Healthy() {
+		t.Error("healthy after reaching the failure threshold")
+	}
+	if got := l.Stats().CompressFailures; got != 2 {
+		t.Errorf("CompressFailures = %d, want 2", got)
+	}
+	if !strings.Contains(buf.String(), "2 consecutive compression failures") {
+		t.Errorf("no notice about the failures in %q", buf.String())
+	}
+}
+
+func TestDebugSample(t *testing.T) {
+	l := newTestLogger(t, Config{Level: "info", DebugSample: 0.1})
+
+	const n = 100000
+	kept := 0
+	for i := 0; i < n; i++ {
+		if l.sampleDebug() {
+			kept++
+		}
+	}
+	// Ten standard deviations either side of 10%
+	if fraction := float64(kept) / n; fraction < 0.09 || fraction > 0.11 {
+		t.Errorf("kept %.4f of DEBUG lines, want about 0.1", fraction)
+	}
+
+	for _, c := range []struct{ set, want float64 }{{-1, 0}, {2, 1}, {0.5, 0.5}} {
+		l.SetDebugSample(c.set)
+		if got := l.DebugSample(); got != c.want {
+			t.Errorf("SetDebugSample(%v): DebugSample() = %v, want %v", c.set, got, c.want)
+		}
+	}
+
+	l.SetDebugSample(1)
+	l.Debugf("kept")
+	l.SetDebugSample(0)
+	l.Debugf("filtered")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	lines := readLines(t, l)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "kept") {
+		t.Errorf("lines = %q, want only the sampled DEBUG line", lines)
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	all := []LogLevel{DEBUG, INFO, JEDI, WARNING, ERROR, FATAL}
+	cases := []struct {
+		filter, level string
+		visible       []LogLevel
+	}{
+		{"ordered", "debug", all},
+		{"ordered", "info", []LogLevel{INFO, JEDI, WARNING, ERROR, FATAL}},
+		{"ordered", "jedi", []LogLevel{JEDI, WARNING, ERROR, FATAL}},
+		{"ordered", "warning", []LogLevel{WARNING, ERROR, FATAL}},
+		{"grouped", "info", []LogLevel{INFO, JEDI, WARNING, ERROR, FATAL}},
+		{"grouped", "jedi", []LogLevel{INFO, JEDI, WARNING, ERROR, FATAL}},
+		{"grouped", "warning", []LogLevel{WARNING, ERROR, FATAL}},
+		{"", "jedi", []LogLevel{JEDI, WARNING, ERROR, FATAL}},
+	}
+	for _, c := range cases {
+		l := newTestLogger(t, Config{LevelFilter: c.filter, Level: c.level})
+		var visible []LogLevel
+		for _, level := range all {
+			if l.passesLevel(level) {
+				visible = append(visible, level)
+			}
+		}
+		if fmt.Sprint(visible) != fmt.Sprint(c.visible) {
+			t.Errorf("LevelFilter %q, Level %q: visible %v, want %v", c.filter, c.level, visible, c.visible)
+		}
+	}
+}
+
+func TestStartupIndex(t *testing.T) {
+	cases := []struct {
+		strategy string
+		names    *regexp.Regexp
+		files    int
+	}{
+		{"reuse", regexp.MustCompile(`^1\.log$`), 1},
+		{"new", regexp.MustCompile(`^[12]\.log$`), 2},
+		{"timestamp", regexp.MustCompile(`^[12]-\d{8}T\d{6}\.log$`), 2},
+	}
+	for _, c := range cases {
+		t.Run(c.strategy, func(t *testing.T) {
+			dir := t.TempDir()
+			var files []string
+			// Each logger stands in for a run of the process
+			for run := 1; run <= 2; run++ {
+				l, err := New("test", dir, "app", Config{StartupIndex: c.strategy})
+				if err != nil {
+					t.Fatalf("New: %v", err)
+				}
+				l.Infof("run %d", run)
+				if err := l.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+				if files, err = l.Files(); err != nil {
+					t.Fatalf("Files: %v", err)
+				}
+			}
+
+			if len(files) != c.files {
+				t.Fatalf("files = %v, want %d", files, c.files)
+			}
+			lines := 0
+			for _, path := range files {
+				if !c.names.MatchString(filepath.Base(path)) {
+					t.Errorf("unexpected file name %s", filepath.Base(path))
+				}
+				lines += len(readFileLines(t, path))
+			}
+			if lines != 2 {
+				t.Errorf("%d lines across the files, want 2", lines)
+			}
+		})
+	}
+}
+
+// setTestConsole points the console at w, coloured or not.
+func setTestConsole(l *Logger, w io.Writer, colorize bool) {
+	l.outMu.Lock()
+	l.console = w
+	l.colorize = colorize
+	l.outMu.Unlock()
+}
+
+// BenchmarkConsole compares coloured and plain console output.
+func BenchmarkConsole(b *testing.B) {
+	for _, colorize := range []bool{false, true} {
+		name := "plain"
+		if colorize {
+			name = "colored"
+		}
+		b.Run(name, func(b *testing.B) {
+			l := newTestLogger(b, Config{})
+			l.SetOutput(io.Discard)
+			setTestConsole(l, io.Discard, colorize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Warningf("request %d handled", i)
+			}
+			if err := l.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDrainPendingReturnsQueuedEntries(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	w := newBlockingWriter()
+	l.SetOutput(w)
+
+	l.Infof("written")
+	<-w.entered
+	for i := 0; i < 5; i++ {
+		l.Infof("pending %d", i)
+	}
+
+	drained := make(chan []LogContent)
+	go func() { drained <- l.DrainPending() }()
+	waitFor(t, "draining to start", l.draining.Load)
+	close(w.release)
+	pending := <-drained
+
+	if len(pending) != 5 {
+		t.Fatalf("drained %d entries, want 5", len(pending))
+	}
+	for i, entry := range pending {
+		if want := fmt.Sprintf("pending %d", i); entry.Message != want {
+			t.Errorf("entry %d = %q, want %q", i, entry.Message, want)
+		}
+	}
+	if got := w.written.String(); strings.Count(got, "\n") != 1 || !strings.Contains(got, "written") {
+		t.Errorf("output = %q, want only the entry taken before draining", got)
+	}
+
+	l.Infof("after draining")
+	if again := l.DrainPending(); again != nil {
+		t.Errorf("second DrainPending() = %v, want nil", again)
+	}
+}
+
+func TestDropNoticeBypassesLevel(t *testing.T) {
+	for _, c := range []struct {
+		noticeLevel string
+		shown       bool
+	}{{"", true}, {"warning", false}} {
+		l := newTestLogger(t, Config{Level: "error", NoticeLevel: c.noticeLevel, QueueSize: 1, OverflowPolicy: "drop"})
+		w := newBlockingWriter()
+		l.SetOutput(w)
+
+		l.Errorf("written")
+		<-w.entered
+		l.Errorf("queued")
+		l.Errorf("dropped")
+		close(w.release)
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+
+		notice := strings.Contains(w.written.String(), "[WARNING] dropped 1 entries")
+		if notice != c.shown {
+			t.Errorf("NoticeLevel %q: notice shown %v, want %v in %q", c.noticeLevel, notice, c.shown, w.written.String())
+		}
+	}
+}
+
+func TestWriteTimeoutAbandonsSlowWrites(t *testing.T) {
+	l := newTestLogger(t, Config{WriteTimeout: 20 * time.Millisecond})
+	w := newBlockingWriter()
+	l.SetOutput(w)
+
+	flushed := make(chan error)
+	go func() {
+		l.Infof("stuck")
+		l.Infof("skipped while stuck")
+		flushed <- l.Flush()
+	}()
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a hung writer wedged the logger")
+	}
+	if got := l.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+	if l.Healthy() {
+		t.Error("healthy after a write timed out")
+	}
+
+	// Once the sink recovers, the next write clears the health error
+	close(w.release)
+	waitFor(t, "the stalled write to finish", func() bool {
+		_, stalled := l.stalledWrites.Load(io.Writer(w))
+		return !stalled
+	})
+	l.Infof("recovered")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !l.Healthy() {
+		t.Error("still unhealthy after a successful write")
+	}
+}
+
+func TestWarnOnce(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l.WarnOnce("deprecated", "option is deprecated (call %d)", i)
+		}(i)
+	}
+	wg.Wait()
+	l.Once(ERROR, "other", "a different key")
+	l.Once(ERROR, "other", "a different key")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 2 || !strings.Contains(lines[0], "option is deprecated") || !strings.Contains(lines[1], "a different key") {
+		t.Errorf("lines = %q, want each key logged once", lines)
+	}
+}
+
+func TestEncryptedLogFile(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	l := newTestLogger(t, Config{EncryptionKey: key})
+	l.Infof("confidential")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil || len(files) != 1 || !strings.HasSuffix(files[0], ".log.enc") {
+		t.Fatalf("Files() = %v, %v; want one .log.enc file", files, err)
+	}
+	if _, err := OpenLogReader(files[0]); !errors.Is(err, ErrEncryptedLog) {
+		t.Errorf("OpenLogReader error = %v, want ErrEncryptedLog", err)
+	}
+
+	r, err := encrypt.OpenEncrypted(files[0], key)
+	if err != nil {
+		t.Fatalf("OpenEncrypted: %v", err)
+	}
+	defer r.Close()
+	plain, err := io.ReadAll(r)
+	if err != nil || !strings.HasSuffix(string(plain), "confidential\n") {
+		t.Errorf("decrypted %q, %v", plain, err)
+	}
+}
+
+func TestFlushWritesEveryQueuedLine(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	for i := 0; i < 1000; i++ {
+		l.Infof("line %d", i)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 1000 {
+		t.Errorf("%d lines after Flush, want 1000", len(lines))
+	}
+
+	// The logger keeps working after a Flush
+	l.Infof("after")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 1001 {
+		t.Errorf("%d lines after the second Flush, want 1001", len(lines))
+	}
+}
+
+func TestBufferedFileIsFlushed(t *testing.T) {
+	// The interval is long enough that only Flush and Close write the buffer
+	l := newTestLogger(t, Config{FileFlushInterval: time.Hour})
+	l.Infof("buffered")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 1 {
+		t.Fatalf("lines after Flush = %q, want the buffered line", lines)
+	}
+
+	l.Infof("at close")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 2 {
+		t.Errorf("lines after Close = %q, want both", lines)
+	}
+}
+
+// BenchmarkFileBuffering compares writing to the log file directly with
+// buffering the writes.
+func BenchmarkFileBuffering(b *testing.B) {
+	for _, c := range []struct {
+		name     string
+		interval time.Duration
+	}{{"unbuffered", 0}, {"buffered", time.Second}} {
+		b.Run(c.name, func(b *testing.B) {
+			l := newTestLogger(b, Config{FileFlushInterval: c.interval})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Infof("request %d handled", i)
+			}
+			if err := l.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// capture redirects *stream to a pipe while fn runs and returns what was
+// written to it.
+func capture(t *testing.T, stream **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *stream
+	*stream = w
+	defer func() { *stream = saved }()
+
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- string(b)
+	}()
+	fn()
+	_ = w.Close()
+	return <-output
+}
+
+func TestConsoleStreamStderr(t *testing.T) {
+	var stdout string
+	stderr := capture(t, &os.Stderr, func() {
+		stdout = capture(t, &os.Stdout, func() {
+			l := newTestLogger(t, Config{Console: true, ConsoleStream: "stderr"})
+			l.Infof("to stderr")
+			if err := l.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		})
+	})
+
+	if !strings.Contains(stderr, "to stderr") {
+		t.Errorf("stderr = %q, want the console line", stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing", stdout)
+	}
+}
+
+func TestFatalfWritesBeforeExit(t *testing.T) {
+	if dir := os.Getenv("LOGGER_FATAL_DIR"); dir != "" {
+		l, err := New("test", dir, "app", Config{FatalExitCode: 3})
+		if err != nil {
+			os.Exit(2)
+		}
+		for i := 0; i < 5000; i++ {
+			l.Infof("line %d", i)
+		}
+		l.Fatalf("giving up")
+		os.Exit(0)
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalfWritesBeforeExit$")
+	cmd.Env = append(os.Environ(), "LOGGER_FATAL_DIR="+dir)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("subprocess exited with %v, want exit status 3", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "app", "*", "*.log"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("log files = %v, %v; want one", files, err)
+	}
+	lines := readFileLines(t, files[0])
+	if len(lines) != 5001 {
+		t.Fatalf("%d lines on disk, want 5001", len(lines))
+	}
+	if last := lines[len(lines)-1]; !strings.Contains(last, "[FATAL]") || !strings.HasSuffix(last, "giving up") {
+		t.Errorf("last line = %q, want the FATAL entry", last)
+	}
+}
+
+func TestNextPeriodStartAlignsToWallClock(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	at := func(s string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04:05", s, newYork)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	cases := []struct {
+		frequency RollFrequency
+		now, want time.Time
+	}{
+		{SECONDLY, at("2024-05-08 10:37:12"), at("2024-05-08 10:37:13")},
+		{MINUTELY, at("2024-05-08 10:37:12"), at("2024-05-08 10:38:00")},
+		{HOURLY, at("2024-05-08 10:37:12"), at("2024-05-08 11:00:00")},
+		{DAILY, at("2024-05-08 10:37:12"), at("2024-05-09 00:00:00")},
+		{WEEKLY, at("2024-05-08 10:37:12"), at("2024-05-13 00:00:00")},
+		{MONTHLY, at("2024-12-08 10:37:12"), at("2025-01-01 00:00:00")},
+		{YEARLY, at("2024-05-08 10:37:12"), at("2025-01-01 00:00:00")},
+		// The days clocks change are 23 and 25 hours long
+		{DAILY, at("2024-03-10 00:30:00"), at("2024-03-11 00:00:00")},
+		{DAILY, at("2024-11-03 00:30:00"), at("2024-11-04 00:00:00")},
+		// 02:00 doesn't exist on 10 March; the hour after 01:00 is 03:00
+		{HOURLY, at("2024-03-10 01:30:00"), at("2024-03-10 03:00:00")},
+	}
+	for _, c := range cases {
+		l := &Logger{rollFrequency: c.frequency}
+		if got := l.nextPeriodStart(c.now); !got.Equal(c.want) {
+			t.Errorf("frequency %d from %v: next period starts %v, want %v", c.frequency, c.now, got, c.want)
+		}
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	l.Infof("one")
+	l.Errorf("two")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	before := l.Stats()
+	if before.Levels[INFO] != 1 || before.Levels[ERROR] != 1 || before.Bytes == 0 {
+		t.Fatalf("Stats() = %+v, want the two lines", before)
+	}
+	if reset := l.ResetStats(); reset.Levels[INFO] != 1 || reset.Bytes != before.Bytes {
+		t.Errorf("ResetStats() = %+v, want the stats before the reset", reset)
+	}
+	if after := l.Stats(); after.Levels[INFO] != 0 || after.Levels[ERROR] != 0 || after.Bytes != 0 {
+		t.Errorf("Stats() after reset = %+v, want zeros", after)
+	}
+
+	l.Infof("three")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if after := l.Stats(); after.Levels[INFO] != 1 {
+		t.Errorf("INFO count = %d after one more line, want 1", after.Levels[INFO])
+	}
+}
+
+func TestResetStatsLosesNoIncrements(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	l.SetOutput(io.Discard)
+	const lines = 5000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < lines; i++ {
+			l.Infof("line %d", i)
+		}
+	}()
+
+	var counted uint64
+	for polling := true; polling; {
+		select {
+		case <-done:
+			polling = false
+		default:
+		}
+		counted += l.ResetStats().Levels[INFO]
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	counted += l.ResetStats().Levels[INFO]
+	if counted != lines {
+		t.Errorf("counted %d lines across resets, want %d", counted, lines)
+	}
+}
+
+func TestGetBytesFromSizeString(t *testing.T) {
+	tests := []struct {
+		size string
+		want int64
+	}{
+		{"512", 512},
+		{"512b", 512},
+		{"16kb", 16 << 10},
+		{"8 MB", 8 << 20},
+		{"1.5GB", 3 << 29},
+		{"2TB", 2 << 40},
+		{" 1tb ", 1 << 40},
+		{"", 0},
+		{"M", 0},
+		{"MB", 0},
+		{"0", 0},
+		{"0MB", 0},
+		{"-1MB", 0},
+		{"0.5b", 0},
+		{"10PB", 0},
+		{"9000000TB", 0},
+		{"ten MB", 0},
+	}
+	for _, tt := range tests {
+		got, err := getBytesFromSizeString(tt.size)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("getBytesFromSizeString(%q) = %d, want an error", tt.size, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("getBytesFromSizeString(%q) = %d, %v, want %d", tt.size, got, err, tt.want)
+		}
+	}
+}
+
+// flakyWriter fails its first fails writes, then keeps what is written.
+type flakyWriter struct {
+	mu      sync.Mutex
+	fails   int
+	calls   int
+	written bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls++
+	if w.calls <= w.fails {
+		return 0, errors.New("no space left on device")
+	}
+	return w.written.Write(p)
+}
+
+func (w *flakyWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written.String()
+}
+
+func TestOnWriteError(t *testing.T) {
+	tests := []struct {
+		policy     string
+		fails      int
+		wantFile   bool
+		wantStderr bool
+	}{
+		{"drop", 1, false, false},
+		{"retry", writeRetryAttempts, true, false},
+		{"retry", writeRetryAttempts + 1, false, false},
+		{"stderr", 1, false, true},
+	}
+	for _, tt := range tests {
+		var reported atomic.Int32
+		w := &flakyWriter{fails: tt.fails}
+		stderr := capture(t, &os.Stderr, func() {
+			l := newTestLogger(t, Config{
+				OnWriteError:         tt.policy,
+				InternalErrorHandler: func(error) { reported.Add(1) },
+			})
+			l.SetOutput(w)
+			l.Infof("important")
+			if err := l.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		})
+
+		name := fmt.Sprintf("%s after %d failures", tt.policy, tt.fails)
+		if got := strings.Contains(w.String(), "important"); got != tt.wantFile {
+			t.Errorf("%s: line written = %v, want %v", name, got, tt.wantFile)
+		}
+		if got := strings.Contains(stderr, "important"); got != tt.wantStderr {
+			t.Errorf("%s: line on stderr = %v, want %v", name, got, tt.wantStderr)
+		}
+		if wantReported := !tt.wantFile; (reported.Load() > 0) != wantReported {
+			t.Errorf("%s: %d errors reported", name, reported.Load())
+		}
+	}
+}
+
+func TestWriteRetryBacksOff(t *testing.T) {
+	l := newTestLogger(t, Config{OnWriteError: "retry", InternalErrorHandler: func(error) {}})
+	w := &flakyWriter{fails: 1 << 30}
+	l.SetOutput(w)
+
+	start := time.Now()
+	l.Infof("lost")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// A failing disk must not be hammered in a tight loop
+	want := writeRetryBackoff * (1<<writeRetryAttempts - 1)
+	if elapsed := time.Since(start); elapsed < want {
+		t.Errorf("gave up after %v, want at least %v of backoff", elapsed, want)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.calls != 1+writeRetryAttempts {
+		t.Errorf("%d write attempts, want %d", w.calls, 1+writeRetryAttempts)
+	}
+}
+
+func TestBufferSizeLosesNothingAcrossRotations(t *testing.T) {
+	l := newTestLogger(t, Config{BufferSize: 64 << 10, MaxSize: "4KB"})
+	line := strings.Repeat("x", 200)
+	for i := 0; i < 100; i++ {
+		l.Infof("%d %s", i, line)
+		if i == 50 {
+			if err := l.Rotate(); err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	next := 0
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Buffered bytes count towards MaxSize, so no file grows to the buffer size
+		if info.Size() > int64(4<<10+len(line)+64) {
+			t.Errorf("%s is %d bytes, past MaxSize", path, info.Size())
+		}
+		for _, got := range readFileLines(t, path) {
+			if want := fmt.Sprintf("%d %s", next, line); !strings.HasSuffix(got, want) {
+				t.Fatalf("%s: got %.40q, want line %d", path, got, next)
+			}
+			next++
+		}
+	}
+	if next != 100 {
+		t.Errorf("%d lines written, want 100", next)
+	}
+}
+
+// countingWriter counts the writes that reach w.
+type countingWriter struct {
+	w     io.Writer
+	calls int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.calls++
+	return c.w.Write(p)
+}
+
+// BenchmarkBufferSize writes lines through a FileWriter with different
+// buffer sizes and reports the file writes, i.e. syscalls, per line.
+func BenchmarkBufferSize(b *testing.B) {
+	line := []byte("2024-01-02T03:04:05.000Z [INFO]    request 42 handled\n")
+	for _, size := range []int{0, defaultBufferSize, 64 << 10} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			fw, err := NewFileWriter(filepath.Join(b.TempDir(), "1.log"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer fw.Close()
+			counter := &countingWriter{w: (*fileSink)(fw)}
+			if size > 0 {
+				fw.buf = bufio.NewWriterSize(counter, size)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if size == 0 {
+					_, err = counter.Write(line)
+				} else {
+					_, err = fw.Write(line)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := fw.Flush(); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(counter.calls)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []LogLevel{DEBUG, INFO, JEDI, WARNING, ERROR, FATAL} {
+		got, ok := ParseLevel(level.String())
+		if !ok || got != level {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", level.String(), got, ok, level)
+		}
+	}
+	if got, ok := ParseLevel(" Warning "); !ok || got != WARNING {
+		t.Errorf("ParseLevel(\" Warning \") = %v, %v; want WARNING", got, ok)
+	}
+	if _, ok := ParseLevel("loud"); ok {
+		t.Error("ParseLevel(\"loud\") reported a level")
+	}
+}
+
+func TestFallbackToStdout(t *testing.T) {
+	// A file where the category directory should be stops the log file
+	// being created
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New("test", dir, "app", Config{}); err == nil {
+		t.Fatal("New succeeded without a log file")
+	}
+
+	var reported []error
+	stdout := capture(t, &os.Stdout, func() {
+		l, err := New("test", dir, "app", Config{
+			FallbackToStdout:     true,
+			InternalErrorHandler: func(err error) { reported = append(reported, err) },
+		})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		l.Infof("to stdout")
+		if err := l.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+
+	if !strings.Contains(stdout, "[INFO]    to stdout") {
+		t.Errorf("stdout = %q, want the log line", stdout)
+	}
+	if len(reported) == 0 {
+		t.Error("the failure to create the log file was not reported")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	l := newTestLogger(t, Config{Level: "info"})
+	l.Batch(INFO, []string{"user created", "email sent"})
+	l.Batch(DEBUG, []string{"below the level"})
+	l.Batch(INFO, nil)
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 3 {
+		t.Fatalf("%d lines, want a header and 2 events: %q", len(lines), lines)
+	}
+	if want := "[INFO]    batch of 2 events"; !strings.HasSuffix(lines[0], want) {
+		t.Errorf("header = %q, want it to end with %q", lines[0], want)
+	}
+	indent := strings.Repeat(" ", strings.Index(lines[0], "batch"))
+	for i, event := range []string{"user created", "email sent"} {
+		if want := indent + "- " + event; lines[i+1] != want {
+			t.Errorf("event line = %q, want %q", lines[i+1], want)
+		}
+	}
+}
+
+func TestBatchJSON(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "json"})
+	l.WithFields(map[string]interface{}{"order": 7}).Batch(WARNING, []string{"a", "b"})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 1 {
+		t.Fatalf("%d lines, want the batch on one line: %q", len(lines), lines)
+	}
+	var entry struct {
+		Level   string
+		Message string
+		Events  []string
+		Order   int
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	if entry.Level != "warning" || entry.Message != "batch of 2 events" || entry.Order != 7 ||
+		len(entry.Events) != 2 || entry.Events[0] != "a" || entry.Events[1] != "b" {
+		t.Errorf("entry = %+v, want the warning batch of a and b with order 7", entry)
+	}
+}
+
+func TestLnavFormat(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "lnav", Level: "debug"})
+	l.WithFields(map[string]interface{}{"user": "luke"}).Jedif("the force is strong")
+	l.Warningf("low fuel")
+	l.Batch(INFO, []string{"event"})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	// The pattern of the lnav format in the README
+	pattern := regexp.MustCompile(`^(?P<timestamp>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}(?:Z|[+-]\d{2}:\d{2})) (?P<level>DEBUG|INFO|NOTICE|WARNING|ERROR|FATAL) (?P<body>.*)$`)
+	want := []struct{ level, body string }{
+		{"NOTICE", "the force is strong user=luke"},
+		{"WARNING", "low fuel"},
+		{"INFO", "batch of 1 events"},
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 4 {
+		t.Fatalf("%d lines, want 4: %q", len(lines), lines)
+	}
+	for i, w := range want {
+		m := pattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			t.Errorf("line %q doesn't match the lnav pattern", lines[i])
+			continue
+		}
+		if m[2] != w.level || m[3] != w.body {
+			t.Errorf("line %q parsed as level %q body %q, want %q %q", lines[i], m[2], m[3], w.level, w.body)
+		}
+	}
+	// Events are continuations, not entries of their own
+	if pattern.MatchString(lines[3]) || !strings.HasSuffix(lines[3], "- event") {
+		t.Errorf("event line = %q, want an indented continuation", lines[3])
+	}
+}
+
+func TestFileAndDirMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't keep Unix permissions")
+	}
+	// Modes no usual umask narrows, unlike the defaults
+	l := newTestLogger(t, Config{FileMode: "0600", DirMode: "0700"})
+	l.Infof("private")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	file, err := l.activeFile(false)
+	if err != nil {
+		t.Fatalf("activeFile: %v", err)
+	}
+	for path, want := range map[string]os.FileMode{
+		file:                              0o600,
+		filepath.Dir(file):                0o700,
+		filepath.Join(l.path, l.category): 0o700,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %v, want %v", path, got, want)
+		}
+	}
+
+	for _, config := range []Config{{FileMode: "0999"}, {DirMode: "rwx"}, {FileMode: "01777"}} {
+		if _, err := New("test", t.TempDir(), "app", config); err == nil {
+			t.Errorf("New accepted %+v", config)
+		}
+	}
+}
+
+func TestFatalNoExit(t *testing.T) {
+	l := newTestLogger(t, Config{FatalNoExit: true})
+	l.Infof("before")
+	l.Fatalf("giving up")
+
+	// The fatal line is on disk by the time Fatalf returns
+	lines := readLines(t, l)
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], "[FATAL]   giving up") {
+		t.Fatalf("lines = %q, want the FATAL entry last", lines)
+	}
+
+	// The logger stays open for the application's own shutdown
+	l.Infof("after")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 3 {
+		t.Errorf("lines = %q, want the line logged after Fatalf", lines)
+	}
+}
+
+func TestFatalExitCodeDefault(t *testing.T) {
+	if dir := os.Getenv("LOGGER_FATAL_DEFAULT_DIR"); dir != "" {
+		l, err := New("test", dir, "app", Config{})
+		if err != nil {
+			os.Exit(2)
+		}
+		l.Fatalf("giving up")
+		os.Exit(0)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalExitCodeDefault$")
+	cmd.Env = append(os.Environ(), "LOGGER_FATAL_DEFAULT_DIR="+t.TempDir())
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Errorf("subprocess exited with %v, want exit status 1", err)
+	}
+}
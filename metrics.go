@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"strconv"
+	"time"
+)
+
+// ByteSize is a byte count attached with WithBytes. Text output renders it in
+// the units MaxSize is given in (B, KB, MB, GB, TB; 1KB = 1024 bytes), e.g.
+// 3.4MB; structured output keeps the plain number.
+type ByteSize int64
+
+func (b ByteSize) String() string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	value := float64(b)
+	unit := 0
+	for (value >= 1024 || value <= -1024) && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatInt(int64(b), 10) + units[0]
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + units[unit]
+}
+
+// WithDuration returns an Entry carrying d under name, rendered like 1.2s in
+// text output.
+func (l *Logger) WithDuration(name string, d time.Duration) *Entry {
+	return l.entry().WithDuration(name, d)
+}
+
+// WithCount returns an Entry carrying the count n under name.
+func (l *Logger) WithCount(name string, n int64) *Entry {
+	return l.entry().WithCount(name, n)
+}
+
+// WithBytes returns an Entry carrying the byte count b under name, rendered
+// like 3.4MB in text output.
+func (l *Logger) WithBytes(name string, b int64) *Entry {
+	return l.entry().WithBytes(name, b)
+}
+
+// WithDuration returns a copy of the entry that also carries d under name.
+func (e *Entry) WithDuration(name string, d time.Duration) *Entry {
+	return e.with(map[string]interface{}{name: d})
+}
+
+// WithCount returns a copy of the entry that also carries the count n under
+// name.
+func (e *Entry) WithCount(name string, n int64) *Entry {
+	return e.with(map[string]interface{}{name: n})
+}
+
+// WithBytes returns a copy of the entry that also carries the byte count b
+// under name.
+func (e *Entry) WithBytes(name string, b int64) *Entry {
+	return e.with(map[string]interface{}{name: ByteSize(b)})
+}
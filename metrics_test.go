@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestByteSizeString(t *testing.T) {
+	cases := map[ByteSize]string{
+		0:       "0B",
+		1023:    "1023B",
+		1024:    "1.0KB",
+		3565158: "3.4MB",
+		5 << 30: "5.0GB",
+		3 << 40: "3.0TB",
+		4 << 50: "4096.0TB",
+		-2048:   "-2.0KB",
+	}
+	for size, want := range cases {
+		if got := size.String(); got != want {
+			t.Errorf("ByteSize(%d).String() = %q, want %q", int64(size), got, want)
+		}
+	}
+}
+
+func TestMetricFields(t *testing.T) {
+	log := func(l *Logger) {
+		l.WithDuration("took", 1200*time.Millisecond).WithCount("rows", 42).WithBytes("size", 3565158).Infof("query")
+	}
+
+	text := newTestLogger(t, Config{})
+	var textBuf syncBuffer
+	text.SetOutput(&textBuf)
+	log(text)
+	if err := text.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if line := strings.TrimSpace(textBuf.String()); !strings.HasSuffix(line, "query rows=42 size=3.4MB took=1.2s") {
+		t.Errorf("text line = %q", line)
+	}
+
+	structured := newTestLogger(t, Config{Format: "json"})
+	var jsonBuf syncBuffer
+	structured.SetOutput(&jsonBuf)
+	log(structured)
+	if err := structured.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonBuf.String()), &line); err != nil {
+		t.Fatalf("invalid JSON %q: %v", jsonBuf.String(), err)
+	}
+	if line["took"] != 1.2 || line["rows"] != float64(42) || line["size"] != float64(3565158) {
+		t.Errorf("JSON line = %v, want numbers", line)
+	}
+}
@@ -0,0 +1,16 @@
+package logger
+
+// NewNop returns a logger that discards everything, for tests and for
+// libraries whose callers don't want logs. It opens no file and starts no
+// goroutine; Close, Flush and SetOutput do nothing, and Rotate and Tail
+// return ErrNoActiveFile. Fatalf still exits.
+func NewNop() *Logger {
+	l := &Logger{
+		config: &Config{},
+		// A closed queue makes every logging call and control a no-op
+		queue: newEntryQueue(0),
+	}
+	l.queue.close()
+	l.disabled.Store(true)
+	return l
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestNop(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	l := NewNop()
+	l.SetOutput(io.Discard)
+	l.Infof("discarded")
+	l.WithFields(map[string]interface{}{"k": 1}).Errorf("discarded")
+	l.Named("db").Warningf("discarded")
+	l.Batch(INFO, []string{"discarded"})
+
+	if err := l.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+	if err := l.Rotate(); !errors.Is(err, ErrNoActiveFile) {
+		t.Errorf("Rotate() = %v, want ErrNoActiveFile", err)
+	}
+	if _, err := l.Tail(1); !errors.Is(err, ErrNoActiveFile) {
+		t.Errorf("Tail() error = %v, want ErrNoActiveFile", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if stats := l.Stats(); stats.Bytes != 0 {
+		t.Errorf("Stats().Bytes = %d, want 0", stats.Bytes)
+	}
+	if n := runtime.NumGoroutine(); n != goroutines {
+		t.Errorf("%d goroutines after using a Nop logger, want %d", n, goroutines)
+	}
+}
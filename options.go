@@ -0,0 +1,74 @@
+package logger
+
+import "strconv"
+
+// Option sets part of the Config NewWithOptions builds.
+type Option func(*Config)
+
+// NewWithOptions creates a logger from the given options, leaving every
+// other setting at its default. The configuration is validated like
+// NewWithConfig, so e.g. an unknown level is rejected.
+func NewWithOptions(name, path, category string, opts ...Option) (*Logger, error) {
+	var config Config
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return NewWithConfig(name, path, category, config)
+}
+
+// WithLevel sets the minimum level written.
+func WithLevel(level LogLevel) Option {
+	return func(config *Config) {
+		config.Level = levelName(level)
+	}
+}
+
+// WithFrequency sets how often a new dated directory is started.
+func WithFrequency(frequency RollFrequency) Option {
+	return func(config *Config) {
+		config.Frequency = frequencyName(frequency)
+	}
+}
+
+// WithMaxSize rotates the file once it reaches size bytes; 0 means no limit.
+func WithMaxSize(size int64) Option {
+	return func(config *Config) {
+		config.MaxSize = strconv.FormatInt(size, 10)
+	}
+}
+
+// WithConsole also writes every line to the console.
+func WithConsole(console bool) Option {
+	return func(config *Config) {
+		config.Console = console
+	}
+}
+
+// WithCompress compresses rotated files.
+func WithCompress(compress bool) Option {
+	return func(config *Config) {
+		config.Compress = compress
+	}
+}
+
+// levelName returns the config name of level, or its number when it has
+// none, which validation then rejects.
+func levelName(level LogLevel) string {
+	for name, value := range levelMapping {
+		if value == level {
+			return name
+		}
+	}
+	return strconv.Itoa(int(level))
+}
+
+// frequencyName returns the config name of frequency, or its number when it
+// has none, which validation then rejects.
+func frequencyName(frequency RollFrequency) string {
+	for name, value := range rollFrequencyMapping {
+		if value == frequency {
+			return name
+		}
+	}
+	return strconv.Itoa(int(frequency))
+}
@@ -0,0 +1,47 @@
+package logger
+
+import "testing"
+
+func TestNewWithOptions(t *testing.T) {
+	l, err := NewWithOptions("test", t.TempDir(), "app",
+		WithLevel(ERROR),
+		WithFrequency(MONTHLY),
+		WithMaxSize(4096),
+		WithCompress(true),
+	)
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	defer l.Close()
+
+	if l.GetLevel() != ERROR || l.rollFrequency != MONTHLY || l.maxSize != 4096 || !l.config.Compress {
+		t.Errorf("level %v, frequency %v, max size %d, compress %v; want the options' settings",
+			l.GetLevel(), l.rollFrequency, l.maxSize, l.config.Compress)
+	}
+	l.Warningf("hidden")
+	l.Errorf("shown")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 1 {
+		t.Errorf("lines = %q, want only the error", lines)
+	}
+}
+
+func TestNewWithOptionsDefaultsAndValidation(t *testing.T) {
+	l, err := NewWithOptions("test", t.TempDir(), "app")
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	defer l.Close()
+	if l.GetLevel() != INFO || l.rollFrequency != DAILY || l.maxSize != 0 {
+		t.Errorf("level %v, frequency %v, max size %d; want the defaults", l.GetLevel(), l.rollFrequency, l.maxSize)
+	}
+
+	if _, err := NewWithOptions("test", t.TempDir(), "app", WithLevel(LogLevel(99))); err == nil {
+		t.Error("NewWithOptions accepted an unknown level")
+	}
+	if _, err := NewWithOptions("test", t.TempDir(), "app", WithMaxSize(-1)); err == nil {
+		t.Error("NewWithOptions accepted a negative size")
+	}
+}
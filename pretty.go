@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// prettyTimeFormat is the short clock shown in the pretty console format.
+const prettyTimeFormat = "15:04:05.000"
+
+// formatPretty renders entry for an interactive terminal: a short clock, the
+// level padded and coloured, the message, and fields as dimmed key=value
+// pairs. When width is known and the line would overflow it, the fields are
+// moved to an indented continuation line instead of wrapping mid-field.
+func (l *Logger) formatPretty(entry LogContent, width int) string {
+	timestamp := entry.Timestamp
+	if l.config.UTC {
+		timestamp = timestamp.UTC()
+	}
+	clock := timestamp.Format(prettyTimeFormat)
+	level := fmt.Sprintf("%-7s", entry.Level.String())
+	prefixWidth := len(clock) + 1 + len(level) + 1
+
+	dim := func(s string) string { return s }
+	paint := func(s string) string { return s }
+	if l.colorize {
+		dim = paintDim
+		paint = func(s string) string { return l.paintLevel(entry.Level, s) }
+	}
+
+	var sb strings.Builder
+	sb.WriteString(dim(clock))
+	sb.WriteString(" ")
+	sb.WriteString(paint(level))
+	sb.WriteString(" ")
+	message := l.message(entry) + formatCaller(entry.Caller)
+	sb.WriteString(message)
+
+	fields := strings.TrimPrefix(formatFields(entry.Fields), " ")
+	if fields != "" {
+		if width > 0 && prefixWidth+len(message)+1+len(fields) > width {
+			sb.WriteString("\n")
+			sb.WriteString(strings.Repeat(" ", prefixWidth))
+		} else {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(dim(fields))
+	}
+	sb.WriteString("\n")
+
+	for _, event := range entry.Events {
+		sb.WriteString(strings.Repeat(" ", prefixWidth))
+		sb.WriteString("- ")
+		sb.WriteString(event)
+		sb.WriteString("\n")
+	}
+	writeStackTrace(&sb, strings.Repeat(" ", prefixWidth), entry.StackTrace)
+
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPrettyConsole(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "pretty"})
+	var console syncBuffer
+	setTestConsole(l, &console, false)
+
+	l.WithFields(map[string]interface{}{"user": "ann"}).Infof("signed in")
+	l.Batch(WARNING, []string{"retry"})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(console.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("console has %d lines, want 3: %q", len(lines), lines)
+	}
+	pattern := regexp.MustCompile(`^\d\d:\d\d:\d\d\.\d{3} INFO    signed in user=ann$`)
+	if !pattern.MatchString(lines[0]) {
+		t.Errorf("console line = %q, want it to match %s", lines[0], pattern)
+	}
+	if want := strings.Repeat(" ", len("15:04:05.000 WARNING ")) + "- retry"; lines[2] != want {
+		t.Errorf("event line = %q, want %q", lines[2], want)
+	}
+
+	// The file still gets plain text
+	file := readLines(t, l)
+	if len(file) == 0 || !strings.HasSuffix(file[0], "[INFO]    signed in user=ann") {
+		t.Errorf("file lines = %q, want the text format", file)
+	}
+}
+
+func TestPrettyWrapsFields(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "pretty"})
+	var console syncBuffer
+	setTestConsole(l, &console, false)
+	l.outMu.Lock()
+	l.consoleWidth = 40
+	l.outMu.Unlock()
+
+	l.WithFields(map[string]interface{}{"request": "0123456789abcdef"}).Infof("handled")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(console.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("console has %d lines, want the fields moved to a second: %q", len(lines), lines)
+	}
+	if !strings.HasSuffix(lines[0], "INFO    handled") {
+		t.Errorf("first line = %q, want it to end with the message", lines[0])
+	}
+	if want := strings.Repeat(" ", len("15:04:05.000 INFO    ")) + "request=0123456789abcdef"; lines[1] != want {
+		t.Errorf("continuation line = %q, want %q", lines[1], want)
+	}
+}
@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Field names used for the origin of an entry with ProcessFields.
+const (
+	HostnameField = "hostname"
+	PIDField      = "pid"
+	ProcessField  = "process"
+)
+
+// processInfo identifies the process writing a log, resolved once at
+// construction so os.Hostname never runs per line.
+type processInfo struct {
+	hostname string
+	pid      string
+	name     string
+}
+
+// newProcessInfo looks up the host name, PID and executable name. A host
+// name that can't be resolved is left empty.
+func newProcessInfo() processInfo {
+	hostname, _ := os.Hostname()
+	return processInfo{
+		hostname: hostname,
+		pid:      strconv.Itoa(os.Getpid()),
+		name:     filepath.Base(os.Args[0]),
+	}
+}
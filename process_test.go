@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessFields(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "json", ProcessFields: true})
+	l.Infof("started")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 1 {
+		t.Fatalf("%d lines, want 1: %q", len(lines), lines)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+
+	hostname, _ := os.Hostname()
+	if entry[HostnameField] != hostname {
+		t.Errorf("%s = %v, want %q", HostnameField, entry[HostnameField], hostname)
+	}
+	// The PID is written as a number
+	if entry[PIDField] != float64(os.Getpid()) {
+		t.Errorf("%s = %v, want %d", PIDField, entry[PIDField], os.Getpid())
+	}
+	if want := filepath.Base(os.Args[0]); entry[ProcessField] != want {
+		t.Errorf("%s = %v, want %q", ProcessField, entry[ProcessField], want)
+	}
+}
+
+func TestProcessFieldsOff(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "json"})
+	l.Infof("started")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 1 {
+		t.Fatalf("%d lines, want 1: %q", len(lines), lines)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	for _, key := range []string{HostnameField, PIDField, ProcessField} {
+		if value, ok := entry[key]; ok {
+			t.Errorf("%s = %v without ProcessFields", key, value)
+		}
+	}
+}
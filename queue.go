@@ -0,0 +1,131 @@
+package logger
+
+import "sync"
+
+// entryQueue is the bounded queue between logging calls and the writer: a
+// ring buffer guarded by a mutex. A logging call holds the mutex only to copy
+// its entry in, and wakes the writer only when the writer has run out of
+// work, where a channel would hand each entry over and wake the writer on
+// its own. The writer takes whatever has accumulated in one go. Entries come
+// out in the order they went in.
+type entryQueue struct {
+	mu      sync.Mutex
+	ring    []LogContent
+	head    int
+	n       int
+	closed  bool
+	waiting int // producers blocked in push until there is room
+	idle    bool
+	wake    chan struct{}
+	space   sync.Cond
+}
+
+// newEntryQueue returns an empty queue holding up to size entries.
+func newEntryQueue(size int) *entryQueue {
+	q := &entryQueue{ring: make([]LogContent, size), wake: make(chan struct{}, 1)}
+	q.space.L = &q.mu
+	return q
+}
+
+// push queues entry. When the queue is full and wait is set it waits for
+// room; otherwise, with evictOldest, the oldest entry is dropped to make room
+// unless it is an instruction to the writer, and the new entry is dropped
+// when there is no room. It reports whether entry was queued and whether an
+// entry, the new one or an evicted one, was dropped. Nothing is queued or
+// dropped once the queue is closed.
+func (q *entryQueue) push(entry LogContent, wait, evictOldest bool) (queued, dropped bool) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return false, false
+	}
+
+	for q.n == len(q.ring) {
+		if wait {
+			// Once waiting, the entry is queued even if the queue is
+			// closed meanwhile: the writer drains waiters before it stops
+			q.waiting++
+			q.space.Wait()
+			q.waiting--
+			continue
+		}
+		if !evictOldest || q.ring[q.head].control != controlNone {
+			// Instructions to the writer are never evicted: someone may
+			// be waiting on them
+			q.mu.Unlock()
+			return false, true
+		}
+		q.ring[q.head] = LogContent{}
+		q.head = (q.head + 1) % len(q.ring)
+		q.n--
+		dropped = true
+	}
+
+	q.ring[(q.head+q.n)%len(q.ring)] = entry
+	q.n++
+	q.unlockAndWake()
+	return true, dropped
+}
+
+// unlockAndWake releases q.mu, signalling the writer if it is waiting for
+// entries. Only the first call after the writer went idle signals, so the
+// send never blocks.
+func (q *entryQueue) unlockAndWake() {
+	idle := q.idle
+	q.idle = false
+	q.mu.Unlock()
+
+	if idle {
+		q.wake <- struct{}{}
+	}
+}
+
+// take waits for entries and moves up to max of them, oldest first, into buf,
+// returning it. It reports false once the queue is closed and drained.
+func (q *entryQueue) take(buf []LogContent, max int) ([]LogContent, bool) {
+	q.mu.Lock()
+	for q.n == 0 {
+		if q.closed && q.waiting == 0 {
+			q.mu.Unlock()
+			return buf[:0], false
+		}
+		q.idle = true
+		q.mu.Unlock()
+		<-q.wake
+		q.mu.Lock()
+	}
+
+	buf = buf[:0]
+	for q.n > 0 && len(buf) < max {
+		buf = append(buf, q.ring[q.head])
+		q.ring[q.head] = LogContent{}
+		q.head = (q.head + 1) % len(q.ring)
+		q.n--
+	}
+	if q.waiting > 0 {
+		q.space.Broadcast()
+	}
+	q.mu.Unlock()
+	return buf, true
+}
+
+// len returns the number of queued entries.
+func (q *entryQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// close stops the queue accepting entries; those already queued, and those
+// of producers waiting for room, are still taken. It reports false if the
+// queue was already closed.
+func (q *entryQueue) close() bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return false
+	}
+	q.closed = true
+	q.unlockAndWake()
+	return true
+}
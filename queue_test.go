@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEntryQueueKeepsOrder(t *testing.T) {
+	q := newEntryQueue(4)
+	for i := 0; i < 3; i++ {
+		q.push(LogContent{Message: fmt.Sprint(i)}, true, false)
+	}
+	batch, ok := q.take(nil, 2)
+	if !ok || len(batch) != 2 || batch[0].Message != "0" || batch[1].Message != "1" {
+		t.Fatalf("take(2) = %v, %v; want entries 0 and 1", batch, ok)
+	}
+	q.push(LogContent{Message: "3"}, true, false)
+	q.close()
+	batch, ok = q.take(batch, 10)
+	if !ok || len(batch) != 2 || batch[0].Message != "2" || batch[1].Message != "3" {
+		t.Fatalf("take(10) = %v, %v; want entries 2 and 3", batch, ok)
+	}
+	if _, ok := q.take(batch, 10); ok {
+		t.Error("take on a closed, drained queue reported entries")
+	}
+	if queued, dropped := q.push(LogContent{}, true, false); queued || dropped {
+		t.Error("push after close queued or dropped the entry")
+	}
+}
+
+func TestEntryQueueEvictsOldest(t *testing.T) {
+	q := newEntryQueue(2)
+	q.push(LogContent{Message: "old"}, false, true)
+	q.push(LogContent{Message: "newer"}, false, true)
+	if queued, dropped := q.push(LogContent{Message: "newest"}, false, true); !queued || !dropped {
+		t.Fatalf("push into a full queue = %v, %v; want queued with one evicted", queued, dropped)
+	}
+	batch, _ := q.take(nil, 10)
+	if len(batch) != 2 || batch[0].Message != "newer" || batch[1].Message != "newest" {
+		t.Errorf("queue holds %v, want newer and newest", batch)
+	}
+
+	// Without evictOldest the new entry is the one dropped
+	q.push(LogContent{Message: "kept"}, false, false)
+	q.push(LogContent{Message: "kept"}, false, false)
+	if queued, dropped := q.push(LogContent{Message: "lost"}, false, false); queued || !dropped {
+		t.Errorf("push into a full queue = %v, %v; want dropped", queued, dropped)
+	}
+}
+
+func TestCloseWritesEntriesOfWaitingProducers(t *testing.T) {
+	l := newTestLogger(t, Config{QueueSize: 1})
+	w := newBlockingWriter()
+	l.SetOutput(w)
+
+	// Stall the writer, fill the queue and leave a producer waiting for room
+	l.Infof("first")
+	<-w.entered
+	l.Infof("second")
+	go l.Infof("third")
+	waitFor(t, "the producer to wait", func() bool {
+		l.queue.mu.Lock()
+		defer l.queue.mu.Unlock()
+		return l.queue.waiting == 1
+	})
+
+	closed := make(chan error)
+	go func() { closed <- l.Close() }()
+	close(w.release)
+	if err := <-closed; err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := strings.Count(w.written.String(), "\n"); got != 3 {
+		t.Errorf("wrote %q, want all three lines", w.written.String())
+	}
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrEncryptedLog is returned by OpenLogReader for encrypted files, which
+// need their key; open them with encrypt.OpenEncrypted instead.
+var ErrEncryptedLog = errors.New("logger: log file is encrypted")
+
+// decompressReader reads a decompressed file and closes both the
+// decompressor and the file.
+type decompressReader struct {
+	io.Reader
+	closeDecompressor func() error
+	file              *os.File
+}
+
+func (r decompressReader) Close() error {
+	err := r.closeDecompressor()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// OpenLogReader opens a log file or archive written by a Logger and returns
+// its plain text: .gz and .zst archives are decompressed, anything else is
+// read as is.
+func OpenLogReader(path string) (io.ReadCloser, error) {
+	ext := filepath.Ext(path)
+	if ext == ".enc" {
+		return nil, ErrEncryptedLog
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ext {
+	case ".gz":
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return decompressReader{Reader: gr, closeDecompressor: gr.Close, file: file}, nil
+	case ".zst":
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		closeDecompressor := func() error {
+			zr.Close()
+			return nil
+		}
+		return decompressReader{Reader: zr, closeDecompressor: closeDecompressor, file: file}, nil
+	default:
+		return file, nil
+	}
+}
+
+// Files returns the paths of every log file and archive of the logger's
+// category, oldest first, ending with the active file. Each can be read with
+// OpenLogReader. A rotated file may be replaced by its archive at any time
+// while compression runs in the background.
+func (l *Logger) Files() ([]string, error) {
+	logFiles, err := l.listLogFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, len(logFiles))
+	for i, file := range logFiles {
+		paths[i] = file.path
+	}
+	return paths, nil
+}
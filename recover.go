@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// Recover logs a panic in progress at ERROR with its stack trace, flushes
+// the log and panics again with the same value. Defer it at the top of a
+// goroutine:
+//
+//	defer log.Recover()
+//
+// It does nothing when there is no panic.
+func (l *Logger) Recover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	l.logPanic(r)
+	panic(r)
+}
+
+// RecoverAndContinue is like Recover but stops the panic once it is logged,
+// so the deferring function returns normally.
+func (l *Logger) RecoverAndContinue() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	l.logPanic(r)
+}
+
+// logPanic writes the recovered value r at ERROR with the stack of the
+// panicking goroutine, whatever the level, and waits for it to be synced.
+func (l *Logger) logPanic(r interface{}) {
+	if l.disabled.Load() {
+		return
+	}
+
+	logContent := LogContent{
+		Level:      ERROR,
+		Timestamp:  time.Now(),
+		Message:    l.redact(fmt.Sprintf("panic: %v", r)),
+		Fields:     l.redactFields(l.fields),
+		StackTrace: l.redact(stackTrace()),
+	}
+	if l.enqueue(logContent) {
+		err := l.Flush()
+		if err != nil {
+			l.reportError(err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+// panicking panics under RecoverAndContinue.
+func panicking(l *Logger) {
+	defer l.RecoverAndContinue()
+	panic("out of range")
+}
+
+func TestRecoverAndContinue(t *testing.T) {
+	// Panics are logged whatever the level
+	l := newTestLogger(t, Config{Level: "fatal"})
+	panicking(l)
+
+	// RecoverAndContinue waits for the entry, so no Flush is needed
+	lines := readLines(t, l)
+	if len(lines) < 2 {
+		t.Fatalf("lines = %q, want the panic and its stack", lines)
+	}
+	if want := "[ERROR]   panic: out of range"; !strings.HasSuffix(lines[0], want) {
+		t.Errorf("line = %q, want it to end with %q", lines[0], want)
+	}
+	stack := strings.Join(lines[1:], "\n")
+	if !strings.Contains(stack, "logger.panicking") {
+		t.Errorf("stack trace doesn't name the panicking function:\n%s", stack)
+	}
+}
+
+func TestRecoverPanicsAgain(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	var value interface{}
+	func() {
+		defer func() { value = recover() }()
+		defer l.Recover()
+		panic("boom")
+	}()
+
+	if value != "boom" {
+		t.Errorf("recovered %v after Recover, want the original panic", value)
+	}
+	if lines := readLines(t, l); len(lines) == 0 || !strings.HasSuffix(lines[0], "panic: boom") {
+		t.Errorf("lines = %q, want the panic logged", lines)
+	}
+}
+
+func TestRecoverWithoutPanic(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	func() {
+		defer l.Recover()
+	}()
+	func() {
+		defer l.RecoverAndContinue()
+	}()
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 0 {
+		t.Errorf("lines = %q, want nothing logged", lines)
+	}
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// redactedText replaces every match of a Redactions pattern.
+const redactedText = "***"
+
+// compileRedactions compiles the Redactions patterns.
+func compileRedactions(patterns []string) ([]*regexp.Regexp, error) {
+	var redactions []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction %q: %w", pattern, err)
+		}
+		redactions = append(redactions, re)
+	}
+	return redactions, nil
+}
+
+// redact masks every match of the logger's redaction patterns in s.
+func (l *Logger) redact(s string) string {
+	for _, re := range l.redactions {
+		s = re.ReplaceAllString(s, redactedText)
+	}
+	return s
+}
+
+// redactedError replaces an error whose message was masked, keeping the name
+// of the original type for JSON output.
+type redactedError struct {
+	message  string
+	typeName string
+}
+
+func (e redactedError) Error() string { return e.message }
+
+// redactFields returns fields with their values masked like messages. Errors
+// and Stringers are masked as their text, and replaced when it changes;
+// numbers and other values are left alone. The map is copied only when a
+// value changes.
+func (l *Logger) redactFields(fields map[string]interface{}) map[string]interface{} {
+	if l.redactions == nil {
+		return fields
+	}
+
+	var redacted map[string]interface{}
+	for key, value := range fields {
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case error:
+			s = v.Error()
+		case fmt.Stringer:
+			s = v.String()
+		default:
+			continue
+		}
+		masked := l.redact(s)
+		if masked == s {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				redacted[k] = v
+			}
+		}
+		if _, ok := value.(error); ok {
+			redacted[key] = redactedError{masked, fmt.Sprintf("%T", value)}
+		} else {
+			redacted[key] = masked
+		}
+	}
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
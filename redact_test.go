@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// cardPattern matches card-number-like runs of 13 to 16 digits, optionally
+// grouped with spaces or dashes.
+const cardPattern = `\b(?:\d[ -]?){12,15}\d\b`
+
+func TestRedactions(t *testing.T) {
+	l := newTestLogger(t, Config{
+		Format:          "json",
+		Redactions:      []string{cardPattern, `main\.run`, `testing\.tRunner`},
+		StackTraceLevel: "error",
+	})
+	var buf syncBuffer
+	l.SetOutput(&buf)
+	l.WithFields(map[string]interface{}{
+		"card":   "4111 1111 1111 1111",
+		"amount": 4111,
+	}).WithError(stackError{"declined 4111-1111-1111-1111"}).Errorf("charged %s", "4111111111111111")
+	l.Batch(INFO, []string{"refund 4111111111111111"})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	output := buf.String()
+	if strings.Contains(output, "4111111111111111") || strings.Contains(output, "1111 1111") || strings.Contains(output, "1111-1111") {
+		t.Errorf("card number written: %s", output)
+	}
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q, want 2", lines)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	want := map[string]interface{}{
+		"card": "***",
+		// Numbers are left alone
+		"amount": float64(4111),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if err, _ := entry[ErrorField].(map[string]interface{}); err["message"] != "declined ***" || err["type"] != "logger.stackError" {
+		t.Errorf("%s = %v, want the message masked and the type kept", ErrorField, entry[ErrorField])
+	}
+	if msg, _ := entry["message"].(string); !strings.HasPrefix(msg, "charged ***") {
+		t.Errorf("message = %q, want the card masked", msg)
+	}
+	// The error's stack is attached after the message is formatted
+	if stack, _ := entry[StackField].(string); !strings.HasPrefix(stack, "***") {
+		t.Errorf("%s = %q, want it masked", StackField, stack)
+	}
+	if trace, _ := entry[StackTraceField].(string); trace == "" || strings.Contains(trace, "testing.tRunner") {
+		t.Errorf("%s = %q, want it masked", StackTraceField, trace)
+	}
+	if !strings.Contains(lines[1], `refund ***`) {
+		t.Errorf("batch line %q, want the event masked", lines[1])
+	}
+}
+
+func TestInvalidRedactionFailsNew(t *testing.T) {
+	_, err := New("test", t.TempDir(), "app", Config{Redactions: []string{cardPattern, "(unclosed"}})
+	if err == nil || !strings.Contains(err.Error(), "(unclosed") {
+		t.Errorf("New error = %v, want one naming the invalid pattern", err)
+	}
+}
@@ -0,0 +1,255 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logFile is a log file or archive found under the category directory.
+type logFile struct {
+	path       string
+	dir        string
+	index      int
+	suffix     string
+	compressed bool
+}
+
+// listLogFiles returns every log file and archive of the logger's category,
+// oldest first: ordered by dated directory, then index, then startup suffix.
+func (l *Logger) listLogFiles() ([]logFile, error) {
+	logCategoryDir := filepath.Join(l.path, l.category)
+	dirs, err := os.ReadDir(logCategoryDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var logFiles []logFile
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(logCategoryDir, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			matches := logFilePattern.FindStringSubmatch(file.Name())
+			if matches == nil {
+				continue
+			}
+			index, err := strconv.Atoi(matches[1])
+			if err != nil {
+				continue
+			}
+			logFiles = append(logFiles, logFile{
+				path:       filepath.Join(logCategoryDir, dir.Name(), file.Name()),
+				dir:        dir.Name(),
+				index:      index,
+				suffix:     matches[2],
+				compressed: matches[3] == ".gz" || matches[3] == ".zst",
+			})
+		}
+	}
+
+	sort.Slice(logFiles, func(i, j int) bool {
+		a, b := logFiles[i], logFiles[j]
+		if a.dir != b.dir {
+			return a.dir < b.dir
+		}
+		if a.index != b.index {
+			return a.index < b.index
+		}
+		return a.suffix < b.suffix
+	})
+
+	return logFiles, nil
+}
+
+// parseAge parses a MaxAge such as "30d", "12h" or "1w": a Go duration, or
+// a whole number of days or weeks.
+func parseAge(age string) (time.Duration, error) {
+	age = strings.TrimSpace(age)
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(age, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(age, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	var d time.Duration
+	if unit == 0 {
+		var err error
+		d, err = time.ParseDuration(age)
+		if err != nil {
+			return 0, fmt.Errorf("invalid max age: %s", age)
+		}
+	} else {
+		n, err := strconv.Atoi(age[:len(age)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid max age: %s", age)
+		}
+		d = time.Duration(n) * unit
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid max age: %s", age)
+	}
+	return d, nil
+}
+
+// removeExpiredDirs removes the dated directories whose period ended more
+// than MaxAge ago. Only names that parse with the current frequency's date
+// format are considered, and the active directory is always kept. It must be
+// called with l.mu held.
+func (l *Logger) removeExpiredDirs() {
+	logCategoryDir := filepath.Join(l.path, l.category)
+	dirs, err := os.ReadDir(logCategoryDir)
+	if err != nil {
+		l.reportError(err)
+		return
+	}
+
+	cutoff := time.Now().Add(-l.maxAge)
+	activeDir := l.periodDir(l.lastRotateTime)
+	for _, dir := range dirs {
+		if !dir.IsDir() || dir.Name() == activeDir {
+			continue
+		}
+		start, err := time.ParseInLocation(getDateFormat(l), dir.Name(), time.Local)
+		if err != nil || l.periodDir(start) != dir.Name() {
+			continue
+		}
+		if l.nextPeriodStart(start).After(cutoff) {
+			continue
+		}
+
+		err = os.RemoveAll(filepath.Join(logCategoryDir, dir.Name()))
+		if err != nil {
+			l.reportError(err)
+		}
+	}
+}
+
+// removeOldBackups removes directories past MaxAge, then enforces
+// MaxUncompressedBackups and MaxCompressedBackups independently, deleting the
+// oldest files of each kind. The active file is never considered.
+func (l *Logger) removeOldBackups() {
+	if l.config.MaxUncompressedBackups <= 0 && l.config.MaxCompressedBackups <= 0 && l.maxAge <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxAge > 0 {
+		l.removeExpiredDirs()
+	}
+	if l.config.MaxUncompressedBackups <= 0 && l.config.MaxCompressedBackups <= 0 {
+		return
+	}
+
+	logFiles, err := l.listLogFiles()
+	if err != nil {
+		l.reportError(err)
+		return
+	}
+
+	activeFile := ""
+	if l.fileWriter != nil {
+		activeFile = l.fileWriter.file.Name()
+	}
+
+	var uncompressed, compressed []logFile
+	for _, file := range logFiles {
+		switch {
+		case file.path == activeFile:
+		case file.compressed:
+			compressed = append(compressed, file)
+		default:
+			uncompressed = append(uncompressed, file)
+		}
+	}
+
+	l.removeOldest(uncompressed, l.config.MaxUncompressedBackups)
+	l.removeOldest(compressed, l.config.MaxCompressedBackups)
+}
+
+// removeOldest deletes all but the newest keep files, which are ordered
+// oldest first. A keep of 0 or less keeps everything.
+func (l *Logger) removeOldest(files []logFile, keep int) {
+	if keep <= 0 || len(files) <= keep {
+		return
+	}
+
+	for _, file := range files[:len(files)-keep] {
+		err := os.Remove(file.path)
+		if err != nil && !os.IsNotExist(err) {
+			l.reportError(err)
+		}
+	}
+}
+
+// Purge deletes every log file, error log and archive of the logger's
+// category except the active files, along with dated directories left empty. It is
+// synchronized with rotation and compression, and logging may continue while
+// it runs. Files that could not be deleted are reported in the returned
+// error.
+func (l *Logger) Purge() error {
+	l.archiveMu.Lock()
+	defer l.archiveMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	logFiles, err := l.listLogFiles()
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(logFiles))
+	for _, file := range logFiles {
+		paths = append(paths, file.path)
+	}
+	errorLogs, err := l.listErrorLogs()
+	if err != nil {
+		return err
+	}
+	paths = append(paths, errorLogs...)
+
+	active := make(map[string]bool)
+	if l.fileWriter != nil {
+		active[l.fileWriter.file.Name()] = true
+	}
+	if l.errorWriter != nil {
+		active[l.errorWriter.file.Name()] = true
+	}
+
+	var errs []error
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		if active[path] {
+			continue
+		}
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+
+	for dir := range dirs {
+		// Fails harmlessly when the directory holds anything else
+		_ = os.Remove(dir)
+	}
+
+	l.deferredCompress = nil
+	l.compressQueue = nil
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,241 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurgeWaitsForCompression(t *testing.T) {
+	l := newTestLogger(t, Config{Compress: true})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	l.compressor = func(inputPath, outputPath string, level int) error {
+		close(started)
+		<-release
+		return compressFile(inputPath, outputPath, level)
+	}
+
+	l.Infof("rotated away")
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	<-started
+
+	purged := make(chan error)
+	go func() { purged <- l.Purge() }()
+	select {
+	case err := <-purged:
+		t.Fatalf("Purge returned while an archive was being written: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-purged; err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Files() = %v, want only the active file", files)
+	}
+}
+
+func TestBackupLimitsAreIndependent(t *testing.T) {
+	l := newTestLogger(t, Config{MaxUncompressedBackups: 1, MaxCompressedBackups: 2})
+	older := l.periodDir(time.Now().AddDate(0, 0, -3))
+	newer := l.periodDir(time.Now().AddDate(0, 0, -2))
+	existing := []string{
+		filepath.Join(older, "1.log.gz"),
+		filepath.Join(older, "2.log.gz"),
+		filepath.Join(older, "3.log"),
+		filepath.Join(newer, "1.log.gz"),
+		filepath.Join(newer, "2.log"),
+	}
+	for _, name := range existing {
+		path := filepath.Join(l.path, l.category, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.removeOldBackups()
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	var kept []string
+	for _, path := range files {
+		rel, _ := filepath.Rel(filepath.Join(l.path, l.category), path)
+		kept = append(kept, rel)
+	}
+	want := []string{
+		filepath.Join(older, "2.log.gz"),
+		filepath.Join(newer, "1.log.gz"),
+		filepath.Join(newer, "2.log"),
+		filepath.Join(l.periodDir(time.Now()), "1.log"),
+	}
+	if strings.Join(kept, " ") != strings.Join(want, " ") {
+		t.Errorf("kept %v, want %v", kept, want)
+	}
+}
+
+func TestPurgeWhileLogging(t *testing.T) {
+	l := newTestLogger(t, Config{RotateEveryNLines: 50, Compress: true})
+	const lines = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < lines; i++ {
+			l.Infof("line %d", i)
+		}
+	}()
+
+	for purging := true; purging; {
+		select {
+		case <-done:
+			purging = false
+		default:
+		}
+		if err := l.Purge(); err != nil {
+			t.Fatalf("Purge: %v", err)
+		}
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if err := l.Purge(); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Files() = %v, want only the active file", files)
+	}
+	// The active file holds the last 50 lines, in order
+	got := readFileLines(t, files[0])
+	if len(got) != 50 {
+		t.Fatalf("active file has %d lines, want 50", len(got))
+	}
+	for i, line := range got {
+		if want := fmt.Sprintf("line %d", lines-50+i); !strings.HasSuffix(line, want) {
+			t.Fatalf("line %d of the active file = %q, want %q", i, line, want)
+		}
+	}
+}
+
+func TestRemoveExpiredDirs(t *testing.T) {
+	l := newTestLogger(t, Config{MaxAge: "7d"})
+	root := filepath.Join(l.path, l.category)
+	dirs := map[string]bool{
+		l.periodDir(time.Now().AddDate(0, 0, -1)):  true,
+		l.periodDir(time.Now().AddDate(0, 0, -6)):  true,
+		l.periodDir(time.Now().AddDate(0, 0, -10)): false,
+		l.periodDir(time.Now().AddDate(0, 0, -40)): false,
+		"not-a-date": true,
+		"2006-1-2":   true,
+	}
+	for name := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.mu.Lock()
+	l.removeExpiredDirs()
+	l.mu.Unlock()
+
+	for name, kept := range dirs {
+		_, err := os.Stat(filepath.Join(root, name))
+		if kept && err != nil {
+			t.Errorf("%s removed: %v", name, err)
+		}
+		if !kept && !os.IsNotExist(err) {
+			t.Errorf("%s kept past MaxAge", name)
+		}
+	}
+
+	// The active directory survives even once it is past the cutoff
+	l.mu.Lock()
+	l.maxAge = time.Nanosecond
+	l.removeExpiredDirs()
+	l.mu.Unlock()
+	if _, err := os.Stat(filepath.Join(root, l.periodDir(time.Now()))); err != nil {
+		t.Errorf("active directory removed: %v", err)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		age  string
+		want time.Duration
+	}{
+		{"12h", 12 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{" 2w ", 14 * 24 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"", 0},
+		{"0d", 0},
+		{"-1h", 0},
+		{"d", 0},
+		{"1.5d", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.age)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("parseAge(%q) = %v, want an error", tt.age, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseAge(%q) = %v, %v, want %v", tt.age, got, err, tt.want)
+		}
+	}
+}
+
+func TestMaxBackupsLimitsArchives(t *testing.T) {
+	l := newTestLogger(t, Config{MaxBackups: 2, Compress: true})
+	for i := 0; i < 4; i++ {
+		l.Infof("file %d", i)
+		if err := l.Rotate(); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+	}
+	// Close waits for the last archive; the limit is applied after each one
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	var archives []string
+	for _, path := range files {
+		if strings.HasSuffix(path, ".gz") {
+			archives = append(archives, path)
+		}
+	}
+	if len(archives) != 2 {
+		t.Fatalf("archives = %v, want the newest 2", archives)
+	}
+	for i, path := range archives {
+		lines := readFileLines(t, path)
+		if len(lines) != 1 || !strings.HasSuffix(lines[0], fmt.Sprintf("file %d", i+2)) {
+			t.Errorf("%s = %q, want file %d", path, lines, i+2)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Rotate starts a new log file straight away, e.g. on SIGHUP when external
+// tooling coordinates rotation. Entries logged before the call go to the old
+// file, which is compressed like any rotated file when Compress is set. When
+// the new file can't be opened the error is returned and logging carries on
+// in the current file. It returns ErrNoActiveFile after SetOutput or Close.
+func (l *Logger) Rotate() error {
+	done := make(chan error, 1)
+	if !l.enqueue(LogContent{control: controlRotate, done: done}) {
+		return ErrNoActiveFile
+	}
+	return <-done
+}
+
+// Compress compresses every rotated file of the logger's category that
+// isn't compressed yet and returns once they are done, whether or not
+// Compress is set and ignoring CompressWindow. The active file and error log
+// are left alone, as are encrypted logs. It returns the errors of the files
+// that failed.
+func (l *Logger) Compress() error {
+	if l.config.EncryptionKey != nil {
+		return nil
+	}
+
+	l.archiveMu.Lock()
+	l.mu.Lock()
+	files, err := l.uncompressedFiles()
+	if err != nil {
+		l.mu.Unlock()
+		l.archiveMu.Unlock()
+		return err
+	}
+	// Every queued file is on disk uncompressed, so it is among files
+	l.compressQueue = nil
+	l.deferredCompress = nil
+	l.mu.Unlock()
+
+	var errs []error
+	compressed, _ := l.archiveFiles(files, func(err error) {
+		errs = append(errs, err)
+	})
+	l.archiveMu.Unlock()
+
+	// New archives count against MaxCompressedBackups too
+	if compressed > 0 {
+		l.removeOldBackups()
+	}
+	return errors.Join(errs...)
+}
+
+// uncompressedFiles returns the log files and error logs of the category
+// that are neither compressed nor open. It must be called with l.mu held.
+func (l *Logger) uncompressedFiles() ([]deferredFile, error) {
+	active := map[string]bool{}
+	if l.fileWriter != nil {
+		active[l.fileWriter.file.Name()] = true
+	}
+	if l.errorWriter != nil {
+		active[l.errorWriter.file.Name()] = true
+	}
+
+	logCategoryDir := filepath.Join(l.path, l.category)
+	dirs, err := os.ReadDir(logCategoryDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []deferredFile
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		dirName := filepath.Join(logCategoryDir, dir.Name())
+		entries, err := os.ReadDir(dirName)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			matches := logFilePattern.FindStringSubmatch(entry.Name())
+			if (matches == nil || matches[3] != "") && entry.Name() != errorLogName {
+				continue
+			}
+			path := filepath.Join(dirName, entry.Name())
+			if !active[path] {
+				files = append(files, deferredFile{path: path, level: l.compressLevel})
+			}
+		}
+	}
+	return files, nil
+}
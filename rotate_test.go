@@ -0,0 +1,393 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRotateEveryNLines(t *testing.T) {
+	l := newTestLogger(t, Config{RotateEveryNLines: 3})
+	for i := 1; i <= 10; i++ {
+		l.Infof("line %d", i)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	want := []int{3, 3, 3, 1}
+	if len(files) != len(want) {
+		t.Fatalf("%d files, want %d: %v", len(files), len(want), files)
+	}
+	line := 1
+	for i, path := range files {
+		lines := readFileLines(t, path)
+		if len(lines) != want[i] {
+			t.Errorf("%s has %d lines, want %d", path, len(lines), want[i])
+		}
+		for _, got := range lines {
+			if suffix := fmt.Sprintf("line %d", line); !strings.HasSuffix(got, suffix) {
+				t.Errorf("%s: got %q, want it to end with %q", path, got, suffix)
+			}
+			line++
+		}
+	}
+}
+
+func TestSecondlyRollsEachSecond(t *testing.T) {
+	l := newTestLogger(t, Config{Frequency: "secondly"})
+	for i := 0; i < 3; i++ {
+		l.Infof("second %d", i)
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		// Sleep into the next second
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+	}
+	// Let compression of the earlier seconds finish
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	dirs := make(map[string]bool)
+	for _, path := range files {
+		dirs[filepath.Dir(path)] = true
+		if lines := readFileLines(t, path); len(lines) != 1 {
+			t.Errorf("%s has %d lines, want 1", path, len(lines))
+		}
+	}
+	if len(files) != 3 || len(dirs) != 3 {
+		t.Errorf("files = %v, want one per second in its own directory", files)
+	}
+}
+
+func TestWrittenResumesFromFileSize(t *testing.T) {
+	dir := t.TempDir()
+	first, err := New("test", dir, "app", Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	first.Infof("from the first run")
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The second run reuses the file and counts from its size
+	second, err := New("test", dir, "app", Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer second.Close()
+	info, err := os.Stat(second.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() == 0 || second.fileWriter.Written() != info.Size() {
+		t.Errorf("Written() = %d, want the file size %d", second.fileWriter.Written(), info.Size())
+	}
+}
+
+// BenchmarkRotationCheck compares the size check made before each write,
+// which reads the bytes counted by the writer, with the os.Stat it replaced.
+func BenchmarkRotationCheck(b *testing.B) {
+	l := newTestLogger(b, Config{MaxSize: "8MB"})
+
+	b.Run("counter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if l.shouldRotate(0) {
+				b.Fatal("rotation due on an empty file")
+			}
+		}
+	})
+	b.Run("stat", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			info, err := os.Stat(l.file.Name())
+			if err != nil || info.Size() >= l.maxSize {
+				b.Fatal("rotation due on an empty file")
+			}
+		}
+	})
+}
+
+// BenchmarkSizeRotation measures logging with a size limit set, where every
+// write is checked against MaxSize.
+func BenchmarkSizeRotation(b *testing.B) {
+	l := newTestLogger(b, Config{MaxSize: "1MB"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Infof("request %d handled", i)
+	}
+	if err := l.Flush(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func TestConcurrentLoggingAcrossRotations(t *testing.T) {
+	l := newTestLogger(t, Config{RotateEveryNLines: 100})
+	const goroutines, lines = 8, 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				l.Infof("g%d line %d", g, i)
+			}
+		}(g)
+	}
+	rotating := make(chan struct{})
+	go func() {
+		defer close(rotating)
+		for i := 0; i < 20; i++ {
+			if err := l.Rotate(); err != nil {
+				t.Errorf("Rotate: %v", err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	<-rotating
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Every line is written once, and each goroutine's lines stay in order
+	next := make([]int, goroutines)
+	for _, line := range readLines(t, l) {
+		var g, i int
+		if _, err := fmt.Sscanf(line[strings.LastIndex(line, " g"):], " g%d line %d", &g, &i); err != nil {
+			t.Fatalf("unexpected line %q", line)
+		}
+		if i != next[g] {
+			t.Fatalf("goroutine %d: got line %d, want %d", g, i, next[g])
+		}
+		next[g]++
+	}
+	for g, n := range next {
+		if n != lines {
+			t.Errorf("goroutine %d: %d lines written, want %d", g, n, lines)
+		}
+	}
+}
+
+func TestMaxSizeZeroNeverRotates(t *testing.T) {
+	for _, maxSize := range []string{"", "0"} {
+		l := newTestLogger(t, Config{MaxSize: maxSize})
+		line := strings.Repeat("x", 1024)
+		// Well past the old 8MB default
+		for i := 0; i < 9*1024; i++ {
+			l.Infof("%s", line)
+		}
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+
+		files, err := l.Files()
+		if err != nil {
+			t.Fatalf("Files: %v", err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("MaxSize %q: %d files, want 1", maxSize, len(files))
+		}
+		info, err := os.Stat(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() <= 8<<20 {
+			t.Errorf("MaxSize %q: file is %d bytes, want more than 8MB", maxSize, info.Size())
+		}
+	}
+}
+
+func TestSizeRotationCompressesEachFileOnce(t *testing.T) {
+	l := newTestLogger(t, Config{MaxSize: "1KB", Compress: true})
+	line := strings.Repeat("x", 200)
+	for i := 0; i < 50; i++ {
+		l.Infof("%d %s", i, line)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(files) < 5 {
+		t.Fatalf("%d files, want several size-based rotations", len(files))
+	}
+	seen := make(map[string]bool)
+	total := 0
+	for i, path := range files {
+		base := strings.TrimSuffix(path, ".gz")
+		if seen[base] {
+			t.Errorf("%s exists both plain and compressed", base)
+		}
+		seen[base] = true
+		if last := i == len(files)-1; last != !strings.HasSuffix(path, ".log.gz") {
+			t.Errorf("%s: only the active file should be left uncompressed", path)
+		}
+		n := len(readFileLines(t, path))
+		if n == 0 {
+			t.Errorf("%s is empty", path)
+		}
+		total += n
+	}
+	if total != 50 {
+		t.Errorf("%d lines across the files, want 50", total)
+	}
+}
+
+func TestRotateAndCompress(t *testing.T) {
+	l := newTestLogger(t, Config{CompressAttempts: 1})
+	for i := 0; i < 2; i++ {
+		l.Infof("file %d", i)
+		if err := l.Rotate(); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+	}
+	l.Infof("file 2")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Files() = %v, want one file per Rotate plus the active one", files)
+	}
+	for i, path := range files {
+		if lines := readFileLines(t, path); len(lines) != 1 || !strings.HasSuffix(lines[0], fmt.Sprintf("file %d", i)) {
+			t.Errorf("%s = %q, want only file %d", path, lines, i)
+		}
+	}
+
+	// Compress archives the rotated files even though Compress isn't set
+	l.compressor = failingCompressor(1)
+	if err := l.Compress(); err == nil {
+		t.Error("Compress() = nil, want the error of the failed file")
+	}
+	if err := l.Compress(); err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	files, err = l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	for i, path := range files {
+		if compressed := strings.HasSuffix(path, ".log.gz"); compressed != (i < 2) {
+			t.Errorf("%s: want the two rotated files compressed and the active one left alone", path)
+		}
+	}
+}
+
+// blockNextFiles claims every index the next rotation of l could use, so
+// opening the next file fails, and returns a func that releases them.
+func blockNextFiles(t *testing.T, l *Logger) func() {
+	t.Helper()
+	dir := filepath.Join(l.path, l.category, l.periodDir(time.Now()))
+	var claimed []string
+	for i := 0; i < maxCreateAttempts; i++ {
+		path := filepath.Join(dir, l.logFileName(2+i))
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		claimed = append(claimed, path)
+	}
+	return func() {
+		for _, path := range claimed {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestFailedSizeRotationKeepsTheFile(t *testing.T) {
+	var reported atomic.Int32
+	l := newTestLogger(t, Config{RotateEveryNLines: 2, InternalErrorHandler: func(error) { reported.Add(1) }})
+	release := blockNextFiles(t, l)
+	for i := 0; i < 5; i++ {
+		l.Infof("line %d", i)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if reported.Load() == 0 {
+		t.Error("the failed rotation wasn't reported")
+	}
+	active := filepath.Join(l.path, l.category, l.periodDir(time.Now()), l.logFileName(1))
+	if lines := readFileLines(t, active); len(lines) != 5 {
+		t.Fatalf("active file has %d lines, want all 5 written while rotation failed", len(lines))
+	}
+
+	// Once a file can be opened again, the next line rotates
+	release()
+	l.Infof("line 5")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Files() = %v, want the full file and the next one", files)
+	}
+	if lines := readFileLines(t, files[1]); len(lines) != 1 || !strings.HasSuffix(lines[0], "line 5") {
+		t.Errorf("next file = %q, want the line logged after the failure", lines)
+	}
+}
+
+func TestFailedRotateKeepsTheFile(t *testing.T) {
+	l := newTestLogger(t, Config{InternalErrorHandler: func(error) {}})
+	release := blockNextFiles(t, l)
+	l.Infof("before")
+	if err := l.Rotate(); err == nil {
+		t.Fatal("Rotate() = nil, want the error opening the next file")
+	}
+	l.Infof("after")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	active := filepath.Join(l.path, l.category, l.periodDir(time.Now()), l.logFileName(1))
+	if lines := readFileLines(t, active); len(lines) != 2 {
+		t.Fatalf("active file = %q, want both lines", lines)
+	}
+
+	release()
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	l.Infof("rotated")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Files() = %v, want two files", files)
+	}
+	if lines := readFileLines(t, files[1]); len(lines) != 1 || !strings.HasSuffix(lines[0], "rotated") {
+		t.Errorf("new file = %q, want the line logged after Rotate", lines)
+	}
+}
@@ -0,0 +1,33 @@
+package logger
+
+import "fmt"
+
+// parseSampling maps the Sampling config to a keep-one-in-N ratio per level,
+// where 0 keeps every line.
+func parseSampling(sampling map[string]int) ([FATAL + 1]uint64, error) {
+	var every [FATAL + 1]uint64
+	for name, n := range sampling {
+		level, ok := levelMapping[name]
+		if !ok {
+			return every, fmt.Errorf("invalid sampling: unknown level %s", name)
+		}
+		if n < 1 {
+			return every, fmt.Errorf("invalid sampling: %s must be at least 1, got %d", name, n)
+		}
+		every[level] = uint64(n)
+	}
+	return every, nil
+}
+
+// sample reports whether a line at level is kept by Sampling: the first of
+// every N lines at that level is, the others are counted as sampled out.
+func (l *Logger) sample(level LogLevel) bool {
+	if level < DEBUG || level > FATAL || l.sampleEvery[level] <= 1 {
+		return true
+	}
+	if (l.sampleCounts[level].Add(1)-1)%l.sampleEvery[level] == 0 {
+		return true
+	}
+	l.sampled.Add(1)
+	return false
+}
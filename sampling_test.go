@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSampling(t *testing.T) {
+	l := newTestLogger(t, Config{Level: "debug", Sampling: map[string]int{"debug": 10, "info": 3}})
+	for i := 0; i < 30; i++ {
+		l.Debugf("debug %d", i)
+		l.Infof("info %d", i)
+		l.Errorf("error %d", i)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	counts := make(map[string][]string)
+	for _, line := range readLines(t, l) {
+		// Messages are "<level> <i>"
+		fields := strings.Fields(line)
+		level, i := fields[len(fields)-2], fields[len(fields)-1]
+		counts[level] = append(counts[level], level+" "+i)
+	}
+	// The first of every N is kept; unlisted levels keep every line
+	want := map[string][]string{
+		"debug": {"debug 0", "debug 10", "debug 20"},
+		"info":  {"info 0", "info 3", "info 6", "info 9", "info 12", "info 15", "info 18", "info 21", "info 24", "info 27"},
+	}
+	for level, messages := range want {
+		if fmt.Sprint(counts[level]) != fmt.Sprint(messages) {
+			t.Errorf("%s lines = %q, want %q", level, counts[level], messages)
+		}
+	}
+	if len(counts["error"]) != 30 {
+		t.Errorf("%d error lines, want all 30", len(counts["error"]))
+	}
+	if got := l.Stats().Sampled; got != 27+20 {
+		t.Errorf("Stats().Sampled = %d, want 47", got)
+	}
+}
+
+func TestSamplingRejectsBadConfig(t *testing.T) {
+	for _, sampling := range []map[string]int{{"loud": 2}, {"info": 0}} {
+		if _, err := New("test", t.TempDir(), "app", Config{Sampling: sampling}); err == nil {
+			t.Errorf("New with Sampling %v succeeded, want an error", sampling)
+		}
+	}
+}
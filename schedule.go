@@ -0,0 +1,315 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// compressWindowCheckInterval is how often the scheduler checks whether the
+// compression window has opened.
+const compressWindowCheckInterval = time.Minute
+
+// compressWindow is a daily window of local time, as offsets from midnight.
+// A window whose end is before its start spans midnight.
+type compressWindow struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// deferredFile is a rotated file waiting to be compressed.
+type deferredFile struct {
+	path  string
+	level int
+}
+
+// parseCompressWindow parses a "HH:MM-HH:MM" window.
+func parseCompressWindow(window string) (*compressWindow, error) {
+	start, end, ok := strings.Cut(window, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid compress window %q: want HH:MM-HH:MM", window)
+	}
+
+	startTime, err := time.Parse("15:04", strings.TrimSpace(start))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compress window %q: %w", window, err)
+	}
+	endTime, err := time.Parse("15:04", strings.TrimSpace(end))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compress window %q: %w", window, err)
+	}
+
+	return &compressWindow{
+		start: time.Duration(startTime.Hour())*time.Hour + time.Duration(startTime.Minute())*time.Minute,
+		end:   time.Duration(endTime.Hour())*time.Hour + time.Duration(endTime.Minute())*time.Minute,
+	}, nil
+}
+
+// contains reports whether t falls inside the window.
+func (w *compressWindow) contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start <= w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// compressOrDefer hands path to the compressor goroutine, or queues it for
+// the compression window when one is configured. It must be called with l.mu
+// held.
+func (l *Logger) compressOrDefer(path string, level int) {
+	file := deferredFile{path: path, level: level}
+	if l.compressWindow != nil {
+		l.deferredCompress = append(l.deferredCompress, file)
+		return
+	}
+
+	l.compressQueue = append(l.compressQueue, file)
+	select {
+	case l.compressWake <- struct{}{}:
+	default:
+	}
+}
+
+// runCompressor compresses the files handed to it by compressOrDefer, so
+// the writer never waits on compression. Once the logger is stopped it compresses
+// what is left and returns.
+func (l *Logger) runCompressor() {
+	defer close(l.compressorDone)
+
+	for {
+		select {
+		case <-l.compressWake:
+		case <-l.quit:
+			l.compressQueued()
+			return
+		}
+		// New archives count against MaxCompressedBackups too
+		if l.compressQueued() > 0 {
+			l.removeOldBackups()
+		}
+	}
+}
+
+// compressQueued compresses the files in the compressor's queue and returns
+// how many were compressed. l.mu is only held to take the queue and record
+// outcomes, not while compressing.
+func (l *Logger) compressQueued() int {
+	l.archiveMu.Lock()
+	defer l.archiveMu.Unlock()
+
+	l.mu.Lock()
+	files := l.compressQueue
+	l.compressQueue = nil
+	l.mu.Unlock()
+
+	compressed, _ := l.archiveFiles(files, l.reportError)
+	return compressed
+}
+
+// archiveFiles compresses files and removes the originals, passing each
+// failure to report, and returns how many were compressed and the files that
+// could not be. Files removed in the meantime, e.g. by retention, are
+// skipped. It must be called with l.archiveMu held.
+func (l *Logger) archiveFiles(files []deferredFile, report func(error)) (compressed int, failed []deferredFile) {
+	for _, file := range files {
+		err := l.archiveWithRetry(file.path, file.level)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		l.mu.Lock()
+		l.recordCompress(file.path, err)
+		l.mu.Unlock()
+		l.emitCompressed(file.path, err)
+		if err != nil {
+			report(err)
+			failed = append(failed, file)
+			continue
+		}
+
+		compressed++
+		err = os.Remove(file.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			report(err)
+		}
+	}
+	return compressed, failed
+}
+
+// runCompressScheduler compresses the queued files whenever a check finds
+// the clock inside the compression window, until the logger is stopped.
+func (l *Logger) runCompressScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.quit:
+			return
+		case <-ticker.C:
+			// New archives count against MaxCompressedBackups too
+			if l.compressWindow.contains(l.clock()) && l.compressDeferred() > 0 {
+				l.removeOldBackups()
+			}
+		}
+	}
+}
+
+// compressDeferred compresses every queued file and returns how many were
+// compressed. Files removed in the meantime, e.g. by retention, are skipped;
+// files that fail stay queued. Like compressQueued, l.mu is only held to
+// take the queue and record outcomes.
+func (l *Logger) compressDeferred() int {
+	l.archiveMu.Lock()
+	defer l.archiveMu.Unlock()
+
+	l.mu.Lock()
+	files := l.deferredCompress
+	l.deferredCompress = nil
+	l.mu.Unlock()
+
+	compressed, failed := l.archiveFiles(files, l.reportError)
+
+	l.mu.Lock()
+	l.deferredCompress = append(failed, l.deferredCompress...)
+	l.mu.Unlock()
+	return compressed
+}
+
+// control is an instruction to the writer passed through the queue.
+type control int
+
+const (
+	controlNone control = iota
+	// controlPeriodEnd asks the writer to close the period that just ended.
+	controlPeriodEnd
+	// controlFlush asks the writer to sync the active file and report the
+	// outcome on the entry's done channel.
+	controlFlush
+	// controlSetOutput asks the writer to close the active file and write to
+	// the entry's output from then on, closing done once it has.
+	controlSetOutput
+	// controlRotate asks the writer to start a new file and report the
+	// outcome on the entry's done channel.
+	controlRotate
+	// controlSummary asks the writer to write the summary line, opening the
+	// new period's file first if the last one was closed.
+	controlSummary
+)
+
+// handleControl carries out an instruction taken off the queue.
+func (l *Logger) handleControl(entry LogContent) {
+	switch entry.control {
+	case controlFlush:
+		var err error
+		l.mu.Lock()
+		if l.fileWriter != nil {
+			err = l.fileWriter.Sync()
+		}
+		if l.errorWriter != nil {
+			err = errors.Join(err, l.errorWriter.Sync())
+		}
+		l.mu.Unlock()
+		entry.done <- err
+	case controlSetOutput:
+		l.mu.Lock()
+		if l.fileWriter != nil {
+			err := l.fileWriter.Close()
+			if err != nil {
+				l.reportError(err)
+			}
+			l.fileWriter = nil
+			l.file = nil
+		}
+		l.outMu.Lock()
+		l.out = entry.output
+		l.outMu.Unlock()
+		l.customOutput = true
+		l.mu.Unlock()
+		close(entry.done)
+	case controlRotate:
+		if l.customOutput {
+			entry.done <- ErrNoActiveFile
+			return
+		}
+		l.compressMu.Lock()
+		err := l.rotate()
+		l.removeOldBackups()
+		l.compressMu.Unlock()
+		entry.done <- err
+	case controlSummary:
+		if !l.customOutput && (l.periodClosed || !time.Now().Before(l.nextRotateTime)) {
+			l.compressMu.Lock()
+			err := l.rotate()
+			if err != nil {
+				l.reportError(err)
+			}
+			l.removeOldBackups()
+			l.compressMu.Unlock()
+		}
+		l.writeSummary()
+	case controlPeriodEnd:
+		l.compressMu.Lock()
+		closed := l.closePeriod()
+		l.compressMu.Unlock()
+		if closed {
+			l.removeOldBackups()
+		}
+	}
+}
+
+// runPeriodTimer wakes the writer at every period boundary, so a period ends
+// on time even when nothing is logged across it, until the logger is
+// stopped.
+func (l *Logger) runPeriodTimer() {
+	for {
+		timer := time.NewTimer(time.Until(l.nextPeriodStart(time.Now())))
+		select {
+		case <-l.quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+			l.enqueue(LogContent{control: controlPeriodEnd})
+		}
+	}
+}
+
+// closePeriod closes the active file once its period has ended and
+// compresses the period's directory. No file is opened for the new period:
+// the next entry does that, so periods without entries leave no empty files
+// behind. It reports whether the period was closed; it is a no-op when the
+// writer already rotated into the new period.
+func (l *Logger) closePeriod() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.periodClosed || l.fileWriter == nil || time.Now().Before(l.nextRotateTime) {
+		return false
+	}
+
+	previousDirName := filepath.Join(l.path, l.category, l.periodDir(l.lastRotateTime))
+
+	finished := l.fileWriter.file.Name()
+	err := l.fileWriter.Close()
+	if err != nil {
+		l.reportError(err)
+	}
+	l.emit(Event{Type: Rotated, Path: finished})
+	l.fileWriter = nil
+	l.file = nil
+	l.closeErrorLog()
+	// Notices written before the next period opens reach the console only
+	l.out = io.Discard
+	l.periodClosed = true
+
+	err = l.compressPreviousUncompressedFiles(previousDirName)
+	if err != nil {
+		l.reportError(err)
+	}
+	return true
+}
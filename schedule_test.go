@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// A window that never opens, so only the test compresses deferred files
+const closedWindow = "00:00-00:00"
+
+func TestCompressDeferredDoesNotBlockWriter(t *testing.T) {
+	l := newTestLogger(t, Config{Compress: true, CompressWindow: closedWindow})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	l.compressor = func(inputPath, outputPath string, level int) error {
+		close(started)
+		<-release
+		return compressFile(inputPath, outputPath, level)
+	}
+
+	l.Infof("deferred")
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	compressed := make(chan int)
+	go func() { compressed <- l.compressDeferred() }()
+	<-started
+
+	flushed := make(chan error)
+	go func() {
+		l.Infof("while compressing")
+		flushed <- l.Flush()
+	}()
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer blocked while deferred files were compressed")
+	}
+
+	close(release)
+	if n := <-compressed; n != 1 {
+		t.Errorf("compressDeferred() = %d, want 1", n)
+	}
+}
+
+func TestCompressDeferredKeepsFailures(t *testing.T) {
+	l := newTestLogger(t, Config{Compress: true, CompressWindow: closedWindow, CompressAttempts: 1})
+	l.compressor = func(string, string, int) error { return errors.New("disk full") }
+
+	l.Infof("deferred")
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if n := l.compressDeferred(); n != 0 {
+		t.Errorf("compressDeferred() = %d, want 0", n)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.deferredCompress) != 1 {
+		t.Errorf("%d files deferred after a failure, want 1", len(l.deferredCompress))
+	}
+}
+
+// waitForEvent returns the next event of type want, failing the test after a
+// few seconds.
+func waitForEvent(t testing.TB, l *Logger, want EventType) Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-l.Events():
+			if event.Type == want {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %v event", want)
+		}
+	}
+}
+
+func TestIdlePeriodIsClosedAndCompressed(t *testing.T) {
+	l := newTestLogger(t, Config{Frequency: "secondly"})
+	l.Infof("before the boundary")
+
+	closed := waitForEvent(t, l, Rotated)
+	l.Infof("after the boundary")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := os.Stat(closed.Path + ".gz"); err != nil {
+		t.Errorf("closed period not compressed: %v", err)
+	}
+	lines := readLines(t, l)
+	if len(lines) != 2 {
+		t.Errorf("lines = %q, want both entries", lines)
+	}
+}
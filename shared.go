@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// sharedDirs tracks the open loggers of this process per category directory.
+// Loggers sharing a directory also share its file names without coordinating
+// indexes, so their lines are marked with the logger name to keep them apart.
+var sharedDirs = struct {
+	sync.Mutex
+	loggers map[string][]*Logger
+}{loggers: make(map[string][]*Logger)}
+
+// joinCategoryDir registers the logger with its category directory. When the
+// directory is already in use, every logger writing to it starts showing its
+// name and the overlap is reported.
+func (l *Logger) joinCategoryDir() {
+	dir := filepath.Join(l.path, l.category)
+
+	sharedDirs.Lock()
+	defer sharedDirs.Unlock()
+
+	others := sharedDirs.loggers[dir]
+	sharedDirs.loggers[dir] = append(others, l)
+	if len(others) == 0 {
+		return
+	}
+
+	for _, other := range others {
+		other.showName.Store(true)
+	}
+	l.showName.Store(true)
+	l.reportError(fmt.Errorf("logger %q shares %s with logger %q; file indexes are not coordinated between them, use separate categories to keep their files apart", l.name, dir, others[0].name))
+}
+
+// leaveCategoryDir unregisters the logger from its category directory.
+func (l *Logger) leaveCategoryDir() {
+	dir := filepath.Join(l.path, l.category)
+
+	sharedDirs.Lock()
+	defer sharedDirs.Unlock()
+
+	loggers := sharedDirs.loggers[dir]
+	for i, other := range loggers {
+		if other == l {
+			loggers = append(loggers[:i:i], loggers[i+1:]...)
+			break
+		}
+	}
+	if len(loggers) == 0 {
+		delete(sharedDirs.loggers, dir)
+	} else {
+		sharedDirs.loggers[dir] = loggers
+	}
+}
+
+// message returns the entry's message as written, prefixed with the logger
+// name when names are shown.
+func (l *Logger) message(entry LogContent) string {
+	if l.showName.Load() {
+		return l.name + ": " + entry.Message
+	}
+	return entry.Message
+}
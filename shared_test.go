@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSharedDirectoryShowsNames(t *testing.T) {
+	dir := t.TempDir()
+	var reported atomic.Int32
+	config := Config{StartupIndex: "new", InternalErrorHandler: func(error) { reported.Add(1) }}
+	api, err := New("api", dir, "app", config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer api.Close()
+	worker, err := New("worker", dir, "app", config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer worker.Close()
+
+	api.Infof("from api")
+	worker.Infof("from worker")
+	for _, l := range []*Logger{api, worker} {
+		if err := l.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+
+	if reported.Load() == 0 {
+		t.Error("sharing the directory wasn't reported")
+	}
+	all := strings.Join(readLines(t, api), "\n")
+	for _, want := range []string{"api: from api", "worker: from worker"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("no line with %q in %q", want, all)
+		}
+	}
+}
+
+func TestSeparateDirectoriesHideNames(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	l.Infof("alone")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, l); len(lines) != 1 || strings.Contains(lines[0], "test: ") {
+		t.Errorf("lines = %q, want the message without the name", lines)
+	}
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// StackField is the field name the verbose form of a logged error is
+// attached under.
+const StackField = "stack"
+
+// StackTraceField is the JSON key the goroutine stack captured with
+// StackTraceLevel is written under.
+const StackTraceField = "stacktrace"
+
+// maxStackTraceSize bounds a captured goroutine stack; deeper stacks are
+// truncated.
+const maxStackTraceSize = 32 << 10
+
+// errorStack returns the detail an error prints with %+v beyond its %v
+// message, such as the stack trace recorded by github.com/pkg/errors. The
+// error is the one attached with WithError or, failing that, the first error
+// among the format arguments. It returns "" when there is no such error or it
+// has nothing more to say.
+func errorStack(fields map[string]interface{}, v []interface{}) string {
+	err, _ := fields[ErrorField].(error)
+	if err == nil {
+		for _, arg := range v {
+			if argErr, ok := arg.(error); ok {
+				err = argErr
+				break
+			}
+		}
+	}
+	if err == nil {
+		return ""
+	}
+	if _, ok := err.(fmt.Formatter); !ok {
+		return ""
+	}
+
+	message := err.Error()
+	verbose := fmt.Sprintf("%+v", err)
+	if verbose == message {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(verbose, message), "\n")
+}
+
+// withStack returns fields with the stack field added when entries at level
+// carry stacks and the logged error has one.
+func (l *Logger) withStack(level LogLevel, fields map[string]interface{}, v []interface{}) map[string]interface{} {
+	if !l.errorStacks || level < l.errorStackLevel {
+		return fields
+	}
+
+	stack := errorStack(fields, v)
+	if stack == "" {
+		return fields
+	}
+
+	merged := make(map[string]interface{}, len(fields)+1)
+	for key, value := range fields {
+		merged[key] = value
+	}
+	merged[StackField] = stack
+	return merged
+}
+
+// stackTrace returns the calling goroutine's stack, starting at the first
+// frame outside this package. As with caller, this package's tests count as
+// outside it.
+func stackTrace() string {
+	buf := make([]byte, maxStackTraceSize)
+	buf = buf[:runtime.Stack(buf, false)]
+	lines := strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+
+	// The goroutine header is followed by a function line and a file line
+	// per frame
+	start := 1
+	for start+1 < len(lines) && strings.HasPrefix(lines[start], packagePrefix) &&
+		!strings.Contains(lines[start+1], "_test.go:") {
+		start += 2
+	}
+	return strings.Join(append(lines[:1:1], lines[start:]...), "\n")
+}
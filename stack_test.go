@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stackError prints a fake stack trace with %+v, like github.com/pkg/errors.
+type stackError struct{ msg string }
+
+func (e stackError) Error() string { return e.msg }
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	_, _ = io.WriteString(s, e.msg)
+	if verb == 'v' && s.Flag('+') {
+		_, _ = io.WriteString(s, "\nmain.run\n\tmain.go:12")
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	plain := errors.New("plain")
+	stacked := stackError{"stacked"}
+	const stack = "main.run\n\tmain.go:12"
+
+	cases := []struct {
+		name       string
+		stackLevel string
+		level      LogLevel
+		fields     map[string]interface{}
+		args       []interface{}
+		want       string
+	}{
+		{"plain error", "", ERROR, nil, []interface{}{plain}, ""},
+		{"error argument", "", ERROR, nil, []interface{}{"x", stacked}, stack},
+		{"WithError", "", FATAL, map[string]interface{}{ErrorField: stacked}, nil, stack},
+		{"below the threshold", "", WARNING, nil, []interface{}{stacked}, ""},
+		{"lowered threshold", "warning", WARNING, nil, []interface{}{stacked}, stack},
+		{"off", "off", ERROR, nil, []interface{}{stacked}, ""},
+	}
+	for _, c := range cases {
+		l := newTestLogger(t, Config{ErrorStackLevel: c.stackLevel})
+		got, _ := l.withStack(c.level, c.fields, c.args)[StackField].(string)
+		if got != c.want {
+			t.Errorf("%s: stack = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestErrorStackInJSON(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "json"})
+	var buf syncBuffer
+	l.SetOutput(&buf)
+	l.Errorf("failed: %v", stackError{"stacked"})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.String()), &line); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if line["message"] != "failed: stacked" || line[StackField] != "main.run\n\tmain.go:12" {
+		t.Errorf("line = %v, want the short message and the stack field", line)
+	}
+}
+
+func TestStackTraceLevel(t *testing.T) {
+	l := newTestLogger(t, Config{StackTraceLevel: "warning"})
+	l.Infof("no stack")
+	l.Warningf("with stack")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) < 5 {
+		t.Fatalf("lines = %q, want the INFO line, the WARNING and its stack", lines)
+	}
+	if !strings.HasSuffix(lines[0], "[INFO]    no stack") || !strings.HasSuffix(lines[1], "[WARNING] with stack") {
+		t.Fatalf("lines = %q, want the INFO line without a stack", lines)
+	}
+	// After the goroutine header the stack starts at the logging call,
+	// indented below the message
+	indent := strings.Repeat(" ", strings.Index(lines[1], "with stack"))
+	if want := indent + "goroutine "; !strings.HasPrefix(lines[2], want) {
+		t.Errorf("stack header = %q, want it to start with %q", lines[2], want)
+	}
+	if want := indent + "github.com/imkiptoo/logger.TestStackTraceLevel("; !strings.HasPrefix(lines[3], want) {
+		t.Errorf("first frame = %q, want it to start with %q", lines[3], want)
+	}
+	if !strings.HasPrefix(lines[4], indent) || !strings.Contains(lines[4], "stack_test.go:") {
+		t.Errorf("first frame's file = %q, want the indented call site", lines[4])
+	}
+}
+
+func TestStackTraceInJSON(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "json", StackTraceLevel: "error"})
+	l.Warningf("no stack")
+	l.Errorf("with stack")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2: %q", len(lines), lines)
+	}
+	var entries [2]map[string]interface{}
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &entries[i]); err != nil {
+			t.Fatalf("invalid JSON %q: %v", line, err)
+		}
+	}
+	if stack, ok := entries[0][StackTraceField]; ok {
+		t.Errorf("WARNING has %s %q, want none below the level", StackTraceField, stack)
+	}
+	stack, _ := entries[1][StackTraceField].(string)
+	if !strings.Contains(stack, "logger.TestStackTraceInJSON(") {
+		t.Errorf("%s = %q, want the stack of the logging call", StackTraceField, stack)
+	}
+}
@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoActiveFile is returned by Tail, Follow and Rotate when the logger has
+// no open log file, e.g. after SetOutput or Close.
+var ErrNoActiveFile = errors.New("logger: no active log file")
+
+// followPollInterval is how often Follow checks for new lines and rotation.
+const followPollInterval = 200 * time.Millisecond
+
+// tailChunkSize is how much of the file Tail reads back at a time.
+const tailChunkSize = 64 << 10
+
+// activeFile returns the path of the active log file. With flush set,
+// buffered lines are written to it first.
+func (l *Logger) activeFile(flush bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fileWriter == nil {
+		return "", ErrNoActiveFile
+	}
+	if l.config.EncryptionKey != nil {
+		return "", ErrEncryptedLog
+	}
+	if flush {
+		err := l.fileWriter.Flush()
+		if err != nil {
+			return "", err
+		}
+	}
+	return l.fileWriter.file.Name(), nil
+}
+
+// Tail returns the last n lines of the active log file, oldest first. Lines
+// still queued are not included; call Flush first to wait for them.
+func (l *Logger) Tail(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	name, err := l.activeFile(true)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	end, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read back from the end until the chunk holds more than n line breaks,
+	// so the first of the n lines is complete
+	var data []byte
+	offset := end
+	for offset > 0 && bytes.Count(data, []byte("\n")) <= n {
+		size := int64(tailChunkSize)
+		if size > offset {
+			size = offset
+		}
+		offset -= size
+
+		chunk := make([]byte, size)
+		_, err := file.ReadAt(chunk, offset)
+		if err != nil {
+			return nil, err
+		}
+		data = append(chunk, data...)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil, nil
+	}
+	return lines, nil
+}
+
+// Follow returns a channel receiving each line written to the log file from
+// now on, following the active file across rotation. Lines reach the channel
+// once they are on disk, so FileFlushInterval delays them. The channel is
+// closed when ctx is cancelled or the logger is closed.
+func (l *Logger) Follow(ctx context.Context) (<-chan string, error) {
+	name, err := l.activeFile(false)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Seek(0, io.SeekEnd)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	lines := make(chan string)
+	go l.follow(ctx, file, lines)
+	return lines, nil
+}
+
+// follow polls file for new lines and sends them, switching to the new
+// active file after rotation once the old one has been read to its end.
+func (l *Logger) follow(ctx context.Context, file *os.File, lines chan<- string) {
+	defer close(lines)
+	defer func() { _ = file.Close() }()
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+
+	reader := bufio.NewReader(file)
+	var partial string
+	send := func() bool {
+		for {
+			chunk, err := reader.ReadString('\n')
+			if err != nil {
+				// Keep a partly written line until the rest arrives
+				partial += chunk
+				return true
+			}
+			select {
+			case lines <- partial + strings.TrimSuffix(chunk, "\n"):
+				partial = ""
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+
+	for {
+		if !send() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.quit:
+			send()
+			return
+		case <-ticker.C:
+		}
+
+		name, err := l.activeFile(false)
+		if err != nil || name == file.Name() {
+			continue
+		}
+		next, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		// Lines written before the rotation are still in the old file
+		if !send() {
+			_ = next.Close()
+			return
+		}
+		_ = file.Close()
+		file = next
+		reader.Reset(file)
+		partial = ""
+	}
+}
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	for i := 1; i <= 5; i++ {
+		l.Infof("line %d", i)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines, err := l.Tail(3)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("Tail(3) returned %d lines: %q", len(lines), lines)
+	}
+	for i, line := range lines {
+		if want := fmt.Sprintf("[INFO]    line %d", i+3); !strings.HasSuffix(line, want) {
+			t.Errorf("line %d = %q, want it to end with %q", i, line, want)
+		}
+	}
+
+	lines, err = l.Tail(10)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if len(lines) != 5 {
+		t.Errorf("Tail(10) returned %d lines, want all 5: %q", len(lines), lines)
+	}
+
+	l.SetOutput(io.Discard)
+	if _, err := l.Tail(1); !errors.Is(err, ErrNoActiveFile) {
+		t.Errorf("Tail after SetOutput: error = %v, want ErrNoActiveFile", err)
+	}
+}
+
+// nextLine returns the next line from lines, failing the test if none
+// arrives in time.
+func nextLine(t *testing.T, lines <-chan string) string {
+	t.Helper()
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			t.Fatal("Follow channel closed early")
+		}
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("no line from Follow")
+	}
+	return ""
+}
+
+func TestFollow(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	l.Infof("before")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines, err := l.Follow(ctx)
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+
+	l.Infof("first")
+	if line := nextLine(t, lines); !strings.HasSuffix(line, "[INFO]    first") {
+		t.Errorf("got %q, want the line written after Follow", line)
+	}
+
+	// Lines written on either side of a rotation arrive in order
+	l.Infof("old file")
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	l.Infof("new file")
+	for _, want := range []string{"old file", "new file"} {
+		if line := nextLine(t, lines); !strings.HasSuffix(line, want) {
+			t.Errorf("got %q, want it to end with %q", line, want)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Error("Follow sent a line after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Follow channel not closed after cancel")
+	}
+}
+
+func TestFollowClosesWithLogger(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	lines, err := l.Follow(context.Background())
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+
+	l.Infof("last")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if line := nextLine(t, lines); !strings.HasSuffix(line, "last") {
+		t.Errorf("got %q, want the line written before Close", line)
+	}
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Error("Follow sent a line after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Follow channel not closed after Close")
+	}
+}
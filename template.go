@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// templatePlaceholders are the names a Template may use.
+var templatePlaceholders = map[string]bool{
+	"time":     true,
+	"level":    true,
+	"name":     true,
+	"category": true,
+	"message":  true,
+	"caller":   true,
+	"fields":   true,
+	"hostname": true,
+	"pid":      true,
+	"process":  true,
+}
+
+// templatePart is a literal run of a template or, when placeholder is set, a
+// value substituted into it.
+type templatePart struct {
+	literal     string
+	placeholder string
+}
+
+// lineTemplate is a parsed Template.
+type lineTemplate []templatePart
+
+// parseTemplate splits template into literals and placeholders, rejecting
+// unknown or unterminated placeholders.
+func parseTemplate(template string) (lineTemplate, error) {
+	var parts lineTemplate
+	rest := template
+	for rest != "" {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			parts = append(parts, templatePart{literal: rest})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, templatePart{literal: rest[:start]})
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid template %q: unterminated placeholder", template)
+		}
+		name := rest[start+1 : start+end]
+		if !templatePlaceholders[name] {
+			return nil, fmt.Errorf("invalid template %q: unknown placeholder {%s}", template, name)
+		}
+		parts = append(parts, templatePart{placeholder: name})
+		rest = rest[start+end+1:]
+	}
+	return parts, nil
+}
+
+// renderTemplate renders entry's first line with the logger's template,
+// without the trailing newline. It also returns the width of the text before
+// the message, which continuation lines are indented by.
+func (l *Logger) renderTemplate(entry LogContent) (string, int) {
+	var sb strings.Builder
+	indent := 0
+	for _, part := range l.template {
+		switch part.placeholder {
+		case "":
+			sb.WriteString(part.literal)
+		case "time":
+			sb.WriteString(l.formatTime(entry.Timestamp))
+		case "level":
+			sb.WriteString(entry.Level.String())
+		case "name":
+			sb.WriteString(l.name)
+		case "category":
+			sb.WriteString(l.category)
+		case "message":
+			indent = sb.Len()
+			sb.WriteString(l.message(entry))
+		case "caller":
+			sb.WriteString(entry.Caller)
+		case "fields":
+			sb.WriteString(strings.TrimPrefix(formatFields(entry.Fields), " "))
+		case "hostname":
+			sb.WriteString(l.process.hostname)
+		case "pid":
+			sb.WriteString(l.process.pid)
+		case "process":
+			sb.WriteString(l.process.name)
+		}
+	}
+	// Empty trailing placeholders, such as {fields}, leave no trailing space
+	return strings.TrimRight(sb.String(), " "), indent
+}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	l := newTestLogger(t, Config{Template: "{level}|{name}/{category}|{pid}|{message} {fields}"})
+	l.WithFields(map[string]interface{}{"id": 7}).Infof("first")
+	l.Warningf("no fields")
+	l.Batch(ERROR, []string{"event"})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	pid := strconv.Itoa(os.Getpid())
+	prefix := "ERROR|test/app|" + pid + "|"
+	want := []string{
+		"INFO|test/app|" + pid + "|first id=7",
+		// An empty {fields} leaves no trailing space
+		"WARNING|test/app|" + pid + "|no fields",
+		prefix + "batch of 1 events",
+		// Continuation lines line up with {message}
+		strings.Repeat(" ", len(prefix)) + "- event",
+	}
+	lines := readLines(t, l)
+	if len(lines) != len(want) {
+		t.Fatalf("%d lines, want %d: %q", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestTemplateProcessPlaceholders(t *testing.T) {
+	l := newTestLogger(t, Config{Template: "{hostname} {process}: {message}"})
+	l.Infof("hello")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	want := hostname + " " + filepath.Base(os.Args[0]) + ": hello"
+	if lines := readLines(t, l); len(lines) != 1 || lines[0] != want {
+		t.Errorf("lines = %q, want [%q]", lines, want)
+	}
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	for _, template := range []string{"{time} {nope}", "{time} {message"} {
+		if _, err := New("test", t.TempDir(), "app", Config{Template: template}); err == nil {
+			t.Errorf("New accepted template %q", template)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package logger
+
+import "os"
+
+// terminalWidth is unknown on this platform, so lines are never reflowed.
+func terminalWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+)
+
+// Field names used for distributed tracing correlation.
+const (
+	TraceIDField = "trace_id"
+	SpanIDField  = "span_id"
+)
+
+// ErrInvalidTraceParent is returned when a traceparent header does not follow
+// the W3C Trace Context format.
+var ErrInvalidTraceParent = errors.New("logger: invalid traceparent header")
+
+// ParseTraceParent extracts the trace and span (parent) IDs from a W3C
+// traceparent header such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceParent(header string) (traceID, spanID string, err error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return "", "", ErrInvalidTraceParent
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || !isLowerHex(flags, 2) {
+		return "", "", ErrInvalidTraceParent
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if version == "00" && len(parts) != 4 {
+		return "", "", ErrInvalidTraceParent
+	}
+	if !isLowerHex(traceID, 32) || isAllZeros(traceID) {
+		return "", "", ErrInvalidTraceParent
+	}
+	if !isLowerHex(spanID, 16) || isAllZeros(spanID) {
+		return "", "", ErrInvalidTraceParent
+	}
+
+	return traceID, spanID, nil
+}
+
+// WithTrace returns an Entry carrying the given trace and span IDs as the
+// trace_id and span_id fields. Empty IDs are omitted.
+func (l *Logger) WithTrace(traceID, spanID string) *Entry {
+	return l.entry().WithTrace(traceID, spanID)
+}
+
+// WithTraceParent parses a W3C traceparent header and returns an Entry
+// carrying its trace and span IDs.
+func (l *Logger) WithTraceParent(header string) (*Entry, error) {
+	return l.entry().WithTraceParent(header)
+}
+
+// WithTrace returns a copy of the entry that also carries the given trace and
+// span IDs. Empty IDs are omitted.
+func (e *Entry) WithTrace(traceID, spanID string) *Entry {
+	fields := make(map[string]interface{}, 2)
+	if traceID != "" {
+		fields[TraceIDField] = traceID
+	}
+	if spanID != "" {
+		fields[SpanIDField] = spanID
+	}
+	return e.with(fields)
+}
+
+// WithTraceParent returns a copy of the entry that also carries the trace and
+// span IDs parsed from a W3C traceparent header.
+func (e *Entry) WithTraceParent(header string) (*Entry, error) {
+	traceID, spanID, err := ParseTraceParent(header)
+	if err != nil {
+		return nil, err
+	}
+	return e.WithTrace(traceID, spanID), nil
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAllZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const spanID = "00f067aa0ba902b7"
+
+	valid := []string{
+		"00-" + traceID + "-" + spanID + "-01",
+		"  00-" + traceID + "-" + spanID + "-00\n",
+		// Later versions may append fields
+		"01-" + traceID + "-" + spanID + "-01-extra",
+	}
+	for _, header := range valid {
+		gotTrace, gotSpan, err := ParseTraceParent(header)
+		if err != nil || gotTrace != traceID || gotSpan != spanID {
+			t.Errorf("ParseTraceParent(%q) = %q, %q, %v", header, gotTrace, gotSpan, err)
+		}
+	}
+
+	malformed := []string{
+		"",
+		"00-" + traceID + "-" + spanID,
+		"00-" + traceID + "-" + spanID + "-01-extra",
+		"ff-" + traceID + "-" + spanID + "-01",
+		"00-" + "4BF92F3577B34DA6A3CE929D0E0E4736" + "-" + spanID + "-01",
+		"00-" + traceID[:31] + "-" + spanID + "-01",
+		"00-00000000000000000000000000000000-" + spanID + "-01",
+		"00-" + traceID + "-0000000000000000-01",
+		"00-" + traceID + "-" + spanID + "-1",
+		"0g-" + traceID + "-" + spanID + "-01",
+	}
+	for _, header := range malformed {
+		if _, _, err := ParseTraceParent(header); !errors.Is(err, ErrInvalidTraceParent) {
+			t.Errorf("ParseTraceParent(%q) error = %v, want ErrInvalidTraceParent", header, err)
+		}
+	}
+}
+
+func TestTraceFieldsAreTopLevelInJSON(t *testing.T) {
+	l := newTestLogger(t, Config{Format: "json"})
+	var buf syncBuffer
+	l.SetOutput(&buf)
+
+	entry, err := l.WithTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("WithTraceParent: %v", err)
+	}
+	entry.Infof("traced")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.String()), &line); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if line[TraceIDField] != "4bf92f3577b34da6a3ce929d0e0e4736" || line[SpanIDField] != "00f067aa0ba902b7" {
+		t.Errorf("line = %v, want top-level trace_id and span_id", line)
+	}
+}
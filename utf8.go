@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// sanitizeUTF8 rewrites invalid UTF-8 in s according to mode: "replace"
+// substitutes U+FFFD for each invalid byte and "escape" writes it as \xNN.
+// Any other mode, and any valid string, returns s unchanged.
+func sanitizeUTF8(s, mode string) string {
+	if (mode != "replace" && mode != "escape") || utf8.ValidString(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			if mode == "escape" {
+				sb.WriteString(fmt.Sprintf("\\x%02x", s[i]))
+			} else {
+				sb.WriteRune(utf8.RuneError)
+			}
+		} else {
+			sb.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return sb.String()
+}
+
+// truncateMessage cuts s to at most max bytes, backing off to the start of a
+// rune so none is split, and appends a marker with the number of bytes cut.
+// A max of 0 or less leaves s unchanged.
+func truncateMessage(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…(truncated %d bytes)", s[:cut], len(s)-cut)
+}
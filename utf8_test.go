@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeUTF8(t *testing.T) {
+	cases := []struct{ in, mode, want string }{
+		{"ok é", "replace", "ok é"},
+		{"bad \xff\xfe!", "keep", "bad \xff\xfe!"},
+		{"bad \xff\xfe!", "replace", "bad ��!"},
+		{"bad \xff\xfe!", "escape", `bad \xff\xfe!`},
+		{"cut \xc3", "escape", `cut \xc3`},
+	}
+	for _, c := range cases {
+		if got := sanitizeUTF8(c.in, c.mode); got != c.want {
+			t.Errorf("sanitizeUTF8(%q, %q) = %q, want %q", c.in, c.mode, got, c.want)
+		}
+	}
+}
+
+func TestInvalidUTF8InOutput(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		for _, mode := range []string{"replace", "escape"} {
+			l := newTestLogger(t, Config{Format: format, InvalidUTF8: mode})
+			var buf syncBuffer
+			l.SetOutput(&buf)
+			l.Infof("payload %s", "\xff\x00ok")
+			if err := l.Flush(); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			out := buf.String()
+			if !utf8.ValidString(out) {
+				t.Errorf("%s/%s: output %q is not valid UTF-8", format, mode, out)
+			}
+			if format != "json" {
+				continue
+			}
+			var line map[string]interface{}
+			if err := json.Unmarshal([]byte(out), &line); err != nil {
+				t.Errorf("%s: invalid JSON %q: %v", mode, out, err)
+				continue
+			}
+			want := "payload �\x00ok"
+			if mode == "escape" {
+				want = `payload \xff` + "\x00ok"
+			}
+			if message, _ := line["message"].(string); message != want {
+				t.Errorf("%s: message %q, want %q", mode, message, want)
+			}
+		}
+	}
+}
+
+func TestMaxMessageLength(t *testing.T) {
+	l := newTestLogger(t, Config{MaxMessageLength: 8})
+	l.WithFields(map[string]interface{}{"note": "fields are kept whole"}).Infof("short")
+	// The cut falls inside "é", which is kept out whole
+	l.Infof("abcdefgé and more")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, l)
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2: %q", len(lines), lines)
+	}
+	if want := "[INFO]    short note=fields are kept whole"; !strings.HasSuffix(lines[0], want) {
+		t.Errorf("line = %q, want it to end with %q", lines[0], want)
+	}
+	if want := "[INFO]    abcdefg…(truncated 11 bytes)"; !strings.HasSuffix(lines[1], want) {
+		t.Errorf("line = %q, want it to end with %q", lines[1], want)
+	}
+}
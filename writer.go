@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"io"
+	"strings"
+)
+
+// levelWriter logs each line written to it at a fixed level.
+type levelWriter struct {
+	logger *Logger
+	level  LogLevel
+}
+
+// Writer returns an io.Writer that logs every line written to it as its own
+// entry at level, e.g. for log.New or http.Server.ErrorLog. A single trailing
+// newline is dropped. Writing at FATAL does not exit.
+func (l *Logger) Writer(level LogLevel) io.Writer {
+	return &levelWriter{logger: l, level: level}
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	text := strings.TrimSuffix(string(p), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		w.logger.logf(w.level, nil, "%s", strings.TrimSuffix(line, "\r"))
+	}
+	return len(p), nil
+}
+
+// SetOutput closes the log file and writes to w instead, e.g. a
+// bytes.Buffer in tests or a writer that does its own rotation. Lines queued
+// before the call still go to the file. Rotation, compression and the
+// reopen check are skipped from then on, as they only apply to the logger's
+// own files.
+func (l *Logger) SetOutput(w io.Writer) {
+	done := make(chan error)
+	if l.enqueue(LogContent{control: controlSetOutput, output: w, done: done}) {
+		<-done
+	}
+}
+
+// AddWriter adds w as an extra sink that receives every line written to the
+// file, without colour. An error from w is reported as an internal error and
+// doesn't affect the other outputs.
+func (l *Logger) AddWriter(w io.Writer) {
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+	l.writers = append(l.writers, w)
+}
+
+// RemoveWriter removes a sink added with AddWriter. It is a no-op when w
+// wasn't added.
+func (l *Logger) RemoveWriter(w io.Writer) {
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+
+	for i, added := range l.writers {
+		if added == w {
+			l.writers = append(l.writers[:i:i], l.writers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSetOutputCapturesLines(t *testing.T) {
+	l := newTestLogger(t, Config{})
+	var buf syncBuffer
+	l.SetOutput(&buf)
+	l.Infof("first %d", 1)
+	l.Warningf("second")
+	l.Errorf("third: %s", "failed")
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []*regexp.Regexp{
+		regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}\S* \[INFO\]    first 1$`),
+		regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}\S* \[WARNING\] second$`),
+		regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}\S* \[ERROR\]   third: failed$`),
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("captured %q, want %d lines", lines, len(want))
+	}
+	for i, re := range want {
+		if !re.MatchString(lines[i]) {
+			t.Errorf("line %d = %q, want it to match %s", i, lines[i], re)
+		}
+	}
+
+	// Nothing reaches the file, and it isn't rotated
+	if err := l.Rotate(); !errors.Is(err, ErrNoActiveFile) {
+		t.Fatalf("Rotate() = %v, want ErrNoActiveFile", err)
+	}
+	files, err := l.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Files() = %v, want only the file opened by New", files)
+	}
+	if got := readFileLines(t, files[0]); len(got) != 0 {
+		t.Errorf("file has %q, want nothing", got)
+	}
+}